@@ -0,0 +1,3 @@
+module github.com/i-hate-nicknames/golang_diy
+
+go 1.24
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// editor is a minimal line editor for terminals: it supports backspace, history
+// navigation with up and down arrows, and tab completion.
+// It relies on stty to switch the terminal to non-canonical mode, so that keys
+// are delivered one by one.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   string
+	history  func() []string
+	complete func(line string) []string
+}
+
+// isTerminal reports whether f is a character device, i.e. most likely a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// stty runs stty on the standard input and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// rawMode puts the terminal in non-canonical mode without echo and signals, so that
+// Ctrl-C reaches the editor as a key, and returns a function that restores the previous state
+func rawMode() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+}
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyTab       = '\t'
+	keyEnter     = '\n'
+	keyReturn    = '\r'
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+// readLine reads a single line, returning io.EOF on Ctrl-D at an empty line
+func (e *editor) readLine() (string, error) {
+	var line []rune
+	history := e.history()
+	// pos is the position in history, len(history) is the line being edited
+	pos := len(history)
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r\033[K%s%s", e.prompt, string(line))
+	}
+	redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case keyEnter, keyReturn:
+			fmt.Fprint(e.out, "\n")
+			return string(line), nil
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+		case keyCtrlC:
+			line = line[:0]
+			fmt.Fprint(e.out, "^C\n")
+			redraw()
+		case keyBackspace, keyCtrlH:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				redraw()
+			}
+		case keyTab:
+			line = e.completeLine(line)
+			redraw()
+		case keyEscape:
+			// arrows are sent as ESC [ A and ESC [ B
+			if b, _ := e.in.ReadByte(); b != '[' {
+				continue
+			}
+			b, _ := e.in.ReadByte()
+			switch {
+			case b == 'A' && pos > 0:
+				pos--
+				line = []rune(history[pos])
+			case b == 'B' && pos < len(history):
+				pos++
+				line = nil
+				if pos < len(history) {
+					line = []rune(history[pos])
+				}
+			}
+			redraw()
+		default:
+			if r >= ' ' {
+				line = append(line, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+// completeLine completes the last word of line: a single candidate is inserted
+// followed by a space, several candidates are printed and the common prefix is inserted
+func (e *editor) completeLine(line []rune) []rune {
+	s := string(line)
+	candidates := e.complete(s)
+	if len(candidates) == 0 {
+		return line
+	}
+	word := s[strings.LastIndexAny(s, " \t")+1:]
+	if len(candidates) == 1 {
+		return []rune(s + strings.TrimPrefix(candidates[0], word) + " ")
+	}
+	fmt.Fprint(e.out, "\n"+strings.Join(candidates, "  ")+"\n")
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return []rune(s + strings.TrimPrefix(prefix, word))
+}
@@ -0,0 +1,98 @@
+// Command repl is an interactive shell for experimenting with built-in handlers,
+// middlewares and routes without writing Go code.
+//
+// Usage:
+//
+//	repl [-history file]
+//
+// Type help to see available commands. On a terminal, tab completes commands and
+// registered names, and up and down arrows walk through the history. History is
+// saved to the file given by -history, if any, and loaded back on the next start.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	historyName := flag.String("history", "", "load and save command history in `file`")
+	flag.Parse()
+
+	s := newSession(os.Stdout)
+	if *historyName != "" {
+		if data, err := os.ReadFile(*historyName); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.TrimSpace(line) != "" {
+					s.history = append(s.history, line)
+				}
+			}
+		}
+	}
+	loaded := len(s.history)
+
+	err := loop(s)
+	if *historyName != "" && len(s.history) > loaded {
+		if herr := saveHistory(*historyName, s.history[loaded:]); herr != nil && err == nil {
+			err = herr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "repl:", err)
+		os.Exit(1)
+	}
+}
+
+// loop reads commands until quit or end of input, using the line editor on terminals
+func loop(s *session) error {
+	readLine := bufio.NewScanner(os.Stdin)
+	next := func() (string, error) {
+		if !readLine.Scan() {
+			if err := readLine.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return readLine.Text(), nil
+	}
+	if isTerminal(os.Stdin) {
+		if restore, err := rawMode(); err == nil {
+			defer restore()
+			e := &editor{
+				in:       bufio.NewReader(os.Stdin),
+				out:      os.Stdout,
+				prompt:   "> ",
+				history:  func() []string { return s.history },
+				complete: s.complete,
+			}
+			next = e.readLine
+		}
+	}
+	for {
+		line, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.exec(line); err == errQuit {
+			return nil
+		}
+	}
+}
+
+func saveHistory(name string, lines []string) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Fprintln(f, line)
+	}
+	return f.Close()
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+	"github.com/i-hate-nicknames/golang_diy/routing/mux"
+)
+
+// chain is a handler with middlewares, referenced by the names of built-ins
+type chain struct {
+	handler string
+	mws     []string
+}
+
+func (c chain) String() string {
+	if len(c.mws) == 0 {
+		return c.handler
+	}
+	return c.handler + " <- " + strings.Join(c.mws, ", ")
+}
+
+// session holds the state of a REPL: defined chains and a router with the routes registered so far
+type session struct {
+	out     io.Writer
+	router  *mux.Router
+	chains  map[string]chain
+	routes  map[string]chain
+	history []string
+}
+
+func newSession(out io.Writer) *session {
+	return &session{
+		out:    out,
+		router: mux.New(),
+		chains: make(map[string]chain),
+		routes: make(map[string]chain),
+	}
+}
+
+type command struct {
+	usage, help string
+	// args tells completion what every argument is, the last kind repeats
+	args []argKind
+	run  func(s *session, args []string) error
+}
+
+type argKind int
+
+const (
+	argNone argKind = iota
+	argFree
+	argHandler
+	argMiddleware
+	argChain
+	argPath
+)
+
+var errQuit = errors.New("quit")
+
+var commands map[string]command
+
+func init() {
+	// assigned in init, because help refers to commands
+	commands = map[string]command{
+		"define": {
+			usage: "define NAME HANDLER [MIDDLEWARE...]",
+			help:  "define a chain of a built-in handler and middlewares, first middleware runs first",
+			args:  []argKind{argFree, argHandler, argMiddleware},
+			run:   (*session).define,
+		},
+		"register": {
+			usage: "register PATH CHAIN",
+			help:  "register a chain, or a single built-in handler, on a path",
+			args:  []argKind{argPath, argChain, argNone},
+			run:   (*session).register,
+		},
+		"use": {
+			usage: "use PATH MIDDLEWARE...",
+			help:  "add middlewares to a path",
+			args:  []argKind{argPath, argMiddleware},
+			run:   (*session).use,
+		},
+		"send": {
+			usage: "send PATH [DATA]",
+			help:  "match a request and print the result",
+			args:  []argKind{argPath, argFree},
+			run:   (*session).send,
+		},
+		"routes": {
+			usage: "routes",
+			help:  "print the route table",
+			run:   (*session).printRoutes,
+		},
+		"chains": {
+			usage: "chains",
+			help:  "print defined chains",
+			run:   (*session).printChains,
+		},
+		"builtins": {
+			usage: "builtins",
+			help:  "print built-in handlers and middlewares",
+			run:   (*session).printBuiltins,
+		},
+		"history": {
+			usage: "history",
+			help:  "print previous commands",
+			run:   (*session).printHistory,
+		},
+		"help": {
+			usage: "help",
+			help:  "print this help",
+			run:   (*session).printHelp,
+		},
+		"quit": {
+			usage: "quit",
+			help:  "leave the REPL",
+			run:   func(*session, []string) error { return errQuit },
+		},
+	}
+}
+
+// exec runs a single line of input. Errors of the command are printed, and only
+// errQuit is returned.
+func (s *session) exec(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	s.history = append(s.history, line)
+	cmd, ok := commands[fields[0]]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command %q, try help\n", fields[0])
+		return nil
+	}
+	args := fields[1:]
+	if fields[0] == "send" {
+		// data is the rest of the line, spaces included
+		args = sendArgs(line)
+	}
+	err := cmd.run(s, args)
+	if err == errQuit {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(s.out, "error:", err)
+	}
+	return nil
+}
+
+func sendArgs(line string) []string {
+	_, rest, _ := strings.Cut(strings.TrimLeft(line, " \t"), " ")
+	rest = strings.TrimLeft(rest, " \t")
+	path, data, _ := strings.Cut(rest, " ")
+	if path == "" {
+		return nil
+	}
+	return []string{path, data}
+}
+
+func (s *session) define(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + commands["define"].usage)
+	}
+	c := chain{handler: args[1], mws: args[2:]}
+	if _, ok := mux.LookupHandler(c.handler); !ok {
+		return fmt.Errorf("unknown handler %q", c.handler)
+	}
+	for _, name := range c.mws {
+		if _, ok := mux.LookupMiddleware(name); !ok {
+			return fmt.Errorf("unknown middleware %q", name)
+		}
+	}
+	s.chains[args[0]] = c
+	fmt.Fprintf(s.out, "%s = %s\n", args[0], c)
+	return nil
+}
+
+func (s *session) register(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: " + commands["register"].usage)
+	}
+	path := args[0]
+	c, ok := s.chains[args[1]]
+	if !ok {
+		if _, ok := mux.LookupHandler(args[1]); !ok {
+			return fmt.Errorf("unknown chain or handler %q", args[1])
+		}
+		c = chain{handler: args[1]}
+	}
+	h, _ := mux.LookupHandler(c.handler)
+	// wrap in reverse, so that the first middleware runs first
+	for i := len(c.mws) - 1; i >= 0; i-- {
+		mw, _ := mux.LookupMiddleware(c.mws[i])
+		h = mw(h)
+	}
+	// middlewares added with use stay on the route
+	rc := s.routes[path]
+	rc.handler = c.String()
+	s.routes[path] = rc
+	s.router.RegisterHandler(path, h)
+	return nil
+}
+
+func (s *session) use(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + commands["use"].usage)
+	}
+	path := args[0]
+	for _, name := range args[1:] {
+		if _, ok := mux.LookupMiddleware(name); !ok {
+			return fmt.Errorf("unknown middleware %q", name)
+		}
+	}
+	rc := s.routes[path]
+	for _, name := range args[1:] {
+		mw, _ := mux.LookupMiddleware(name)
+		s.router.UseMiddleware(path, mw)
+		rc.mws = append(rc.mws, name)
+	}
+	s.routes[path] = rc
+	return nil
+}
+
+func (s *session) send(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: " + commands["send"].usage)
+	}
+	res, err := s.router.Match(routing.Request{Path: args[0], Data: args[1]})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%q\n", res)
+	return nil
+}
+
+func (s *session) printRoutes(args []string) error {
+	for _, path := range sortedKeys(s.routes) {
+		rc := s.routes[path]
+		handler := rc.handler
+		if handler == "" {
+			handler = "(no handler)"
+		}
+		mws := ""
+		if len(rc.mws) > 0 {
+			mws = " [" + strings.Join(rc.mws, ", ") + "]"
+		}
+		fmt.Fprintf(s.out, "%s\t%s%s\n", path, handler, mws)
+	}
+	return nil
+}
+
+func (s *session) printChains(args []string) error {
+	for _, name := range sortedKeys(s.chains) {
+		fmt.Fprintf(s.out, "%s = %s\n", name, s.chains[name])
+	}
+	return nil
+}
+
+func (s *session) printBuiltins(args []string) error {
+	fmt.Fprintln(s.out, "handlers:", strings.Join(mux.HandlerNames(), " "))
+	fmt.Fprintln(s.out, "middlewares:", strings.Join(mux.MiddlewareNames(), " "))
+	return nil
+}
+
+func (s *session) printHistory(args []string) error {
+	for i, line := range s.history {
+		fmt.Fprintf(s.out, "%4d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+func (s *session) printHelp(args []string) error {
+	for _, name := range sortedKeys(commands) {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "  %-38s %s\n", cmd.usage, cmd.help)
+	}
+	return nil
+}
+
+// complete returns candidates for the last, possibly empty, word of line
+func (s *session) complete(line string) []string {
+	fields := strings.Fields(line)
+	word := ""
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\t") {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+	var names []string
+	if len(fields) == 0 {
+		names = sortedKeys(commands)
+	} else {
+		cmd, ok := commands[fields[0]]
+		if !ok || len(cmd.args) == 0 {
+			return nil
+		}
+		i := len(fields) - 1
+		if i >= len(cmd.args) {
+			i = len(cmd.args) - 1
+		}
+		names = s.names(cmd.args[i])
+	}
+	var res []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+func (s *session) names(kind argKind) []string {
+	switch kind {
+	case argHandler:
+		return mux.HandlerNames()
+	case argMiddleware:
+		return mux.MiddlewareNames()
+	case argChain:
+		names := sortedKeys(s.chains)
+		names = append(names, mux.HandlerNames()...)
+		sort.Strings(names)
+		return names
+	case argPath:
+		return sortedKeys(s.routes)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
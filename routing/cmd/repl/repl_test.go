@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	var out bytes.Buffer
+	s := newSession(&out)
+	script := []string{
+		"define shout upper bangify",
+		"register /shout shout",
+		"send /shout hello world",
+		"register /rev reverse",
+		"use /rev capitalize",
+		"send /rev abc",
+		"send /nope abc",
+		"define c identity reverse capitalize",
+		"register /c c",
+		"send /c abc",
+		"routes",
+	}
+	for _, line := range script {
+		if err := s.exec(line); err != nil {
+			t.Fatalf("line: %s, unexpected error: %v", line, err)
+		}
+	}
+	expected := `shout = upper <- bangify
+"HELLO WORLD!"
+"cbA"
+error: no handler registered for path "/nope"
+c = identity <- reverse, capitalize
+"Cba"
+/c	identity <- reverse, capitalize
+/rev	reverse [capitalize]
+/shout	upper <- bangify
+`
+	if out.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if err := s.exec("quit"); err != errQuit {
+		t.Errorf("expected quit, got: %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := newSession(io.Discard)
+	s.exec("define shout upper bangify")
+	s.exec("register /shout shout")
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{"builtins", "chains", "define", "help", "history", "quit", "register", "routes", "send", "use"}},
+		{"r", []string{"register", "routes"}},
+		{"send ", []string{"/shout"}},
+		{"register /a sh", []string{"shout"}},
+		{"define x up", []string{"upper"}},
+		{"define x upper re", []string{"reverse"}},
+		{"define x upper reverse bang", []string{"bangify"}},
+		{"register /a shout ", nil},
+		{"nope ", nil},
+	}
+	for _, test := range tests {
+		if res := s.complete(test.line); !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("line: %q, expected: %v, got: %v", test.line, test.expected, res)
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	s := newSession(io.Discard)
+	s.exec("define shout upper")
+	input := "reg\t/a sh\t\n" + // completion
+		"\x1b[A\x7f\x7fx\n" + // previous line, edited
+		"\x04" // Ctrl-D
+	e := &editor{
+		in:       bufio.NewReader(strings.NewReader(input)),
+		out:      io.Discard,
+		history:  func() []string { return s.history },
+		complete: s.complete,
+	}
+	expected := []string{"register /a shout ", "register /a shoux"}
+	for _, exp := range expected {
+		line, err := e.readLine()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != exp {
+			t.Errorf("expected line: %q, got: %q", exp, line)
+		}
+		s.exec(line)
+	}
+	if _, err := e.readLine(); err != io.EOF {
+		t.Errorf("expected EOF, got: %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+// Command route runs requests through a routing table described by a configuration file.
+//
+// Usage:
+//
+//	route -config routes.json [-in requests.txt] [-format lines|json] [-out text|json] [-dead-letters file]
+//	route -config routes.json -dead-letters file -replay [-out text|json]
+//	route -config routes.json -check recording [-out text|json]
+//
+// Requests are read from the file given by -in, or from standard input. With -format lines
+// every non-empty line is a request: the path, whitespace, and the rest of the line is data.
+// With -format json the input is a stream of {"path": ..., "data": ...} objects.
+// Every request produces one line of output. The exit status is 1 if any request failed.
+//
+// With -dead-letters, failed requests are appended to the given file. After fixing the
+// routes, run again with -replay to match the dead-lettered requests instead of the input.
+// The file is then rewritten to hold only the requests that failed again.
+//
+// With -record, every request that matched a route is written to the given recording along
+// with its result. Run with -check to replay a recording against the current routes: only
+// the requests whose result changed are printed, and the exit status is 1 if there are any.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+	"github.com/i-hate-nicknames/golang_diy/routing/mux"
+)
+
+// errFailed is returned by run when at least one request failed to match
+var errFailed = errors.New("some requests failed")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if errors.Is(err, errFailed) {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "route:", err)
+		os.Exit(2)
+	}
+}
+
+type record struct {
+	Path  string `json:"path"`
+	Data  string `json:"data"`
+	Out   string `json:"output,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("route", flag.ContinueOnError)
+	configName := fs.String("config", "", "route configuration `file` (required)")
+	inName := fs.String("in", "", "read requests from `file` instead of standard input")
+	format := fs.String("format", "lines", "input format: lines or json")
+	out := fs.String("out", "text", "output format: text or json")
+	deadName := fs.String("dead-letters", "", "append failed requests to `file`")
+	replay := fs.Bool("replay", false, "replay the requests from the -dead-letters file")
+	recordName := fs.String("record", "", "record requests and their results to `file`")
+	checkName := fs.String("check", "", "replay the recording `file` and print results that differ")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configName == "" {
+		return errors.New("-config is required")
+	}
+	if *out != "text" && *out != "json" {
+		return fmt.Errorf("unknown output format %q", *out)
+	}
+	if *replay && *deadName == "" {
+		return errors.New("-replay requires -dead-letters")
+	}
+	conf, err := mux.LoadConfig(*configName)
+	if err != nil {
+		return err
+	}
+
+	if *checkName != "" {
+		router := mux.New()
+		if err := conf.Apply(router); err != nil {
+			return err
+		}
+		return check(router, *checkName, *out, stdout)
+	}
+
+	var opts []mux.Option
+	var recorder *mux.Recorder
+	if *recordName != "" {
+		if recorder, err = mux.CreateRecorder(*recordName); err != nil {
+			return err
+		}
+		// closed explicitly below to report lost lines, this only covers early returns
+		defer recorder.Close()
+		opts = append(opts, mux.WithMiddleware(recorder.Middleware()))
+	}
+	var sinkErr error
+	deadOpts := mux.DeadLetterOptions{OnError: func(err error) {
+		if sinkErr == nil {
+			sinkErr = err
+		}
+	}}
+	var letters []mux.DeadLetter
+	var again *mux.DeadLetterRing
+	var deadFile *mux.DeadLetterFile
+	switch {
+	case *replay:
+		if letters, err = readDeadLetters(*deadName); err != nil {
+			return err
+		}
+		again = mux.NewDeadLetterRing(max(len(letters), 1))
+		opts = append(opts, mux.WithDeadLetters(again, deadOpts))
+	case *deadName != "":
+		if deadFile, err = mux.OpenDeadLetterFile(*deadName); err != nil {
+			return err
+		}
+		// closed explicitly below to report lost letters, this only covers early returns
+		defer deadFile.Close()
+		opts = append(opts, mux.WithDeadLetters(deadFile, deadOpts))
+	}
+	router := mux.New(opts...)
+	if err := conf.Apply(router); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+	// flushed explicitly below to report lost output, this only covers early returns
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	failed := false
+	match := func(req routing.Request) error {
+		rec := record{Path: req.Path, Data: req.Data}
+		res, err := router.Match(req)
+		if err != nil {
+			failed = true
+			rec.Error = err.Error()
+		} else {
+			rec.Out = res
+		}
+		if *out == "json" {
+			return enc.Encode(rec)
+		}
+		if rec.Error != "" {
+			_, err = fmt.Fprintf(w, "%s\terror: %s\n", rec.Path, rec.Error)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\t%s\n", rec.Path, rec.Out)
+		}
+		return err
+	}
+	if *replay {
+		for _, d := range letters {
+			if err := match(d.Request()); err != nil {
+				return err
+			}
+		}
+		if err := writeDeadLetters(*deadName, again.Letters()); err != nil {
+			return err
+		}
+	} else {
+		in := stdin
+		if *inName != "" {
+			f, err := os.Open(*inName)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		if err := readRequests(in, *format, match); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			return fmt.Errorf("recording: %w", err)
+		}
+	}
+	if deadFile != nil {
+		if err := deadFile.Close(); err != nil && sinkErr == nil {
+			sinkErr = err
+		}
+	}
+	if sinkErr != nil {
+		return fmt.Errorf("dead letters: %w", sinkErr)
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// readRequests decodes requests from r in the given format and calls f on each of them
+func readRequests(r io.Reader, format string, f func(routing.Request) error) error {
+	switch format {
+	case "lines":
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			line := sc.Text()
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			path, data, _ := strings.Cut(line, " ")
+			if before, after, ok := strings.Cut(line, "\t"); ok && len(before) < len(path) {
+				path, data = before, after
+			}
+			if err := f(routing.Request{Path: path, Data: data}); err != nil {
+				return err
+			}
+		}
+		return sc.Err()
+	case "json":
+		dec := json.NewDecoder(r)
+		for {
+			var rec record
+			err := dec.Decode(&rec)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("decode request: %w", err)
+			}
+			if err := f(routing.Request{Path: rec.Path, Data: rec.Data}); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown input format %q", format)
+	}
+}
+
+// difference is a request from a recording whose result changed
+type difference struct {
+	Path     string  `json:"path"`
+	Data     string  `json:"data"`
+	Expected outcome `json:"expected"`
+	Got      outcome `json:"got"`
+}
+
+type outcome struct {
+	Out   string `json:"output,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// check replays the named recording against router and writes the differences to stdout.
+// It returns errFailed if there are any.
+func check(router *mux.Router, name, format string, stdout io.Writer) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	recs, err := mux.ReadRecording(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	diffs := router.Replay(context.Background(), recs)
+
+	w := bufio.NewWriter(stdout)
+	// flushed explicitly below to report lost output, this only covers early returns
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	result := func(out, err string) string {
+		if err != "" {
+			return "error: " + err
+		}
+		return out
+	}
+	for _, d := range diffs {
+		if format == "json" {
+			err = enc.Encode(difference{
+				Path:     d.Recorded.Path,
+				Data:     d.Recorded.Data,
+				Expected: outcome{Out: d.Recorded.Output, Error: d.Recorded.Error},
+				Got:      outcome{Out: d.Output, Error: d.Error},
+			})
+		} else {
+			_, err = fmt.Fprintf(w, "%s %s\texpected: %s\tgot: %s\n", d.Recorded.Path, d.Recorded.Data,
+				result(d.Recorded.Output, d.Recorded.Error), result(d.Output, d.Error))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(diffs) > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// readDeadLetters reads the named dead letter file
+func readDeadLetters(name string) ([]mux.DeadLetter, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	letters, err := mux.ReadDeadLetters(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return letters, nil
+}
+
+// writeDeadLetters replaces the named dead letter file with letters
+func writeDeadLetters(name string, letters []mux.DeadLetter) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := mux.WriteDeadLetters(f, letters); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
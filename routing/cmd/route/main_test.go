@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{"routes": [
+	{"path": "/shout", "handler": "upper", "middleware": ["bangify"]},
+	{"path": "/rev", "handler": "reverse"}
+]}`
+
+func writeConfig(t *testing.T) string {
+	name := filepath.Join(t.TempDir(), "routes.json")
+	if err := os.WriteFile(name, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestRun(t *testing.T) {
+	conf := writeConfig(t)
+	tests := []struct {
+		name          string
+		args          []string
+		input, output string
+		err           error
+	}{
+		{
+			name:   "lines",
+			args:   []string{"-config", conf},
+			input:  "/shout hello world\n\n/rev\tabc\n",
+			output: "/shout\tHELLO WORLD!\n/rev\tcba\n",
+		},
+		{
+			name:   "json",
+			args:   []string{"-config", conf, "-format", "json", "-out", "json"},
+			input:  `{"path": "/rev", "data": "ab"} {"path": "/nope", "data": "x"}`,
+			output: "{\"path\":\"/rev\",\"data\":\"ab\",\"output\":\"ba\"}\n{\"path\":\"/nope\",\"data\":\"x\",\"error\":\"no handler registered for path \\\"/nope\\\"\"}\n",
+			err:    errFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(test.args, strings.NewReader(test.input), &out)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected error: %v, got: %v", test.err, err)
+			}
+			if out.String() != test.output {
+				t.Errorf("expected output:\n%s\ngot:\n%s", test.output, out.String())
+			}
+		})
+	}
+}
+
+func TestRunBadArgs(t *testing.T) {
+	conf := writeConfig(t)
+	for _, args := range [][]string{
+		{},
+		{"-config", conf, "-format", "xml"},
+		{"-config", conf, "-out", "xml"},
+		{"-config", filepath.Join(t.TempDir(), "missing.json")},
+		{"-config", conf, "-replay"},
+		{"-config", conf, "-replay", "-dead-letters", filepath.Join(t.TempDir(), "missing.jsonl")},
+	} {
+		var out bytes.Buffer
+		if err := run(args, strings.NewReader("/rev a\n"), &out); err == nil || errors.Is(err, errFailed) {
+			t.Errorf("args: %v, expected usage error, got: %v", args, err)
+		}
+	}
+}
+
+func TestRunDeadLetters(t *testing.T) {
+	conf := writeConfig(t)
+	dead := filepath.Join(t.TempDir(), "dead.jsonl")
+	var out bytes.Buffer
+	err := run([]string{"-config", conf, "-dead-letters", dead}, strings.NewReader("/rev ab\n/shot x\n/nope y\n"), &out)
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected failed requests, got: %v", err)
+	}
+
+	// "fix" one of the routes and replay
+	fixed := filepath.Join(t.TempDir(), "fixed.json")
+	if err := os.WriteFile(fixed, []byte(`{"routes": [{"path": "/shot", "handler": "upper"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err = run([]string{"-config", fixed, "-dead-letters", dead, "-replay"}, strings.NewReader(""), &out)
+	if !errors.Is(err, errFailed) {
+		t.Errorf("expected /nope to fail again, got: %v", err)
+	}
+	expected := "/shot\tX\n/nope\terror: no handler registered for path \"/nope\"\n"
+	if out.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// only the request that failed again is left
+	out.Reset()
+	if err := run([]string{"-config", fixed, "-dead-letters", dead, "-replay", "-out", "json"}, strings.NewReader(""), &out); !errors.Is(err, errFailed) {
+		t.Errorf("expected /nope to fail again, got: %v", err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 1 || !strings.Contains(out.String(), `"/nope"`) {
+		t.Errorf("expected only /nope to be replayed, got:\n%s", out.String())
+	}
+}
+
+func TestRunRecordCheck(t *testing.T) {
+	conf := writeConfig(t)
+	recording := filepath.Join(t.TempDir(), "recording.jsonl")
+	var out bytes.Buffer
+	if err := run([]string{"-config", conf, "-record", recording}, strings.NewReader("/rev ab\n/shout hi\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := run([]string{"-config", conf, "-check", recording}, strings.NewReader(""), &out); err != nil || out.Len() != 0 {
+		t.Errorf("expected no differences with the same routes, got: %v, output:\n%s", err, out.String())
+	}
+
+	changed := filepath.Join(t.TempDir(), "changed.json")
+	if err := os.WriteFile(changed, []byte(`{"routes": [{"path": "/shout", "handler": "upper"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err := run([]string{"-config", changed, "-check", recording}, strings.NewReader(""), &out)
+	if !errors.Is(err, errFailed) {
+		t.Errorf("expected differences to fail the check, got: %v", err)
+	}
+	expected := "/rev ab\texpected: ba\tgot: error: no handler registered for path \"/rev\"\n/shout hi\texpected: HI!\tgot: HI\n"
+	if out.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	run([]string{"-config", changed, "-check", recording, "-out", "json"}, strings.NewReader(""), &out)
+	if line, _, _ := strings.Cut(out.String(), "\n"); line != `{"path":"/rev","data":"ab","expected":{"output":"ba"},"got":{"error":"no handler registered for path \"/rev\""}}` {
+		t.Errorf("unexpected JSON difference: %s", line)
+	}
+}
+
+// brokenPipe is a writer that always fails
+type brokenPipe struct{}
+
+func (brokenPipe) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestRunOutputError(t *testing.T) {
+	conf := writeConfig(t)
+	err := run([]string{"-config", conf}, strings.NewReader("/rev ab\n"), brokenPipe{})
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("expected output error, got: %v", err)
+	}
+
+	recording := filepath.Join(t.TempDir(), "recording.jsonl")
+	if err := run([]string{"-config", conf, "-record", recording}, strings.NewReader("/rev ab\n"), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(t.TempDir(), "changed.json")
+	if err := os.WriteFile(changed, []byte(`{"routes": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-config", changed, "-check", recording}, strings.NewReader(""), brokenPipe{})
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("expected output error from -check, got: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrNoHealthyMembers is returned by a Pool when all its members are unhealthy or ejected.
+var ErrNoHealthyMembers = errors.New("no healthy pool members")
+
+// Strategy picks a member of a Pool for a request.
+type Strategy int
+
+const (
+	// RoundRobin picks members in turn.
+	RoundRobin Strategy = iota
+	// LeastInFlight picks the member with the fewest requests running, the first one on ties.
+	LeastInFlight
+	// PowerOfTwo picks two distinct members at random and takes the one with fewer requests running.
+	PowerOfTwo
+)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	Strategy Strategy
+	// EjectAfter is the number of consecutive failures after which a member is ejected
+	// from the pool, ejection is disabled if zero
+	EjectAfter int
+	// EjectFor is how long an ejected member stays out of the pool
+	EjectFor time.Duration
+	// Clock is SystemClock if not set
+	Clock Clock
+	// Rand is used by PowerOfTwo, a randomly seeded source if not set
+	Rand *rand.Rand
+}
+
+// MemberStats describe a member of a Pool.
+type MemberStats struct {
+	Name     string
+	InFlight int
+	// Healthy is false if the member was marked unhealthy with SetHealthy
+	Healthy bool
+	// EjectedUntil is the time until which the member is ejected, zero if it is not
+	EjectedUntil time.Time
+	Requests     uint64
+	Failures     uint64
+}
+
+type member struct {
+	name     string
+	handler  Handler
+	inFlight int
+	healthy  bool
+	// failures is the number of consecutive failures
+	failures     int
+	ejectedUntil time.Time
+	requests     uint64
+	totalFails   uint64
+}
+
+// Pool balances requests between equivalent handlers, replicas of a service. Members can
+// be taken out of rotation manually with SetHealthy, and automatically for a while when
+// they fail too many times in a row (outlier ejection).
+type Pool struct {
+	opts  PoolOptions
+	clock Clock
+
+	mu      sync.Mutex
+	members []*member
+	next    int
+	rand    *rand.Rand
+}
+
+// NewPool creates a pool without members.
+func NewPool(opts PoolOptions) *Pool {
+	p := &Pool{opts: opts, clock: clockOrSystem(opts.Clock), rand: opts.Rand}
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return p
+}
+
+// Add adds a healthy member to the pool.
+func (p *Pool) Add(name string, h Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.members = append(p.members, &member{name: name, handler: h, healthy: true})
+}
+
+// SetHealthy marks the named member healthy or not. Unhealthy members get no requests.
+func (p *Pool) SetHealthy(name string, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.members {
+		if m.name == name {
+			m.healthy = healthy
+		}
+	}
+}
+
+// Stats returns the state of every member, in the order they were added.
+func (p *Pool) Stats() []MemberStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]MemberStats, len(p.members))
+	for i, m := range p.members {
+		stats[i] = MemberStats{
+			Name:         m.name,
+			InFlight:     m.inFlight,
+			Healthy:      m.healthy,
+			EjectedUntil: m.ejectedUntil,
+			Requests:     m.requests,
+			Failures:     m.totalFails,
+		}
+	}
+	return stats
+}
+
+// available returns members that can take requests. p.mu must be held.
+func (p *Pool) available() []*member {
+	now := p.clock.Now()
+	var ms []*member
+	for _, m := range p.members {
+		if m.healthy && !now.Before(m.ejectedUntil) {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// pick chooses a member for a request, nil if none is available. p.mu must be held.
+func (p *Pool) pick() *member {
+	ms := p.available()
+	if len(ms) == 0 {
+		return nil
+	}
+	switch p.opts.Strategy {
+	case LeastInFlight:
+		best := ms[0]
+		for _, m := range ms[1:] {
+			if m.inFlight < best.inFlight {
+				best = m
+			}
+		}
+		return best
+	case PowerOfTwo:
+		if len(ms) == 1 {
+			return ms[0]
+		}
+		// two distinct members: j is picked among the others and skips over i
+		i, j := p.rand.IntN(len(ms)), p.rand.IntN(len(ms)-1)
+		if j >= i {
+			j++
+		}
+		a, b := ms[i], ms[j]
+		if b.inFlight < a.inFlight {
+			return b
+		}
+		return a
+	default:
+		m := ms[p.next%len(ms)]
+		p.next++
+		return m
+	}
+}
+
+// done records the outcome of a request served by m
+func (p *Pool) done(m *member, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m.inFlight--
+	if err == nil {
+		m.failures = 0
+		return
+	}
+	m.failures++
+	m.totalFails++
+	if p.opts.EjectAfter > 0 && m.failures >= p.opts.EjectAfter {
+		m.failures = 0
+		m.ejectedUntil = p.clock.Now().Add(p.opts.EjectFor)
+	}
+}
+
+// Handler returns a handler that passes every request to a member of the pool.
+func (p *Pool) Handler() Handler {
+	return func(ctx context.Context, in string) (out string, err error) {
+		p.mu.Lock()
+		m := p.pick()
+		if m == nil {
+			p.mu.Unlock()
+			return "", ErrNoHealthyMembers
+		}
+		m.inFlight++
+		m.requests++
+		p.mu.Unlock()
+
+		// a panicking member counts as failed
+		defer func() { p.done(m, err) }()
+		err = errPanicked
+		return m.handler(ctx, in)
+	}
+}
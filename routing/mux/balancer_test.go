@@ -0,0 +1,121 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestPoolRoundRobin(t *testing.T) {
+	p := NewPool(PoolOptions{Strategy: RoundRobin})
+	for _, name := range []string{"a", "b", "c"} {
+		p.Add(name, constant(name))
+	}
+	r := New()
+	r.Handle("/svc", p.Handler())
+	var got []string
+	match := func() {
+		out, err := r.Match(routing.Request{Path: "/svc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSuffix(out, ":"))
+	}
+	for range 4 {
+		match()
+	}
+	p.SetHealthy("b", false)
+	for range 2 {
+		match()
+	}
+	if s := strings.Join(got, ""); s != "abcaac" {
+		t.Errorf("expected members in turn, skipping unhealthy b: abcaac, got: %s", s)
+	}
+}
+
+func TestPoolLeastInFlight(t *testing.T) {
+	for _, strategy := range []Strategy{LeastInFlight, PowerOfTwo} {
+		p := NewPool(PoolOptions{Strategy: strategy, Rand: rand.New(rand.NewPCG(1, 2))})
+		release := make(chan struct{})
+		p.Add("busy", func(ctx context.Context, in string) (string, error) {
+			<-release
+			return "busy", nil
+		})
+		p.Add("idle", constant("idle"))
+		h := p.Handler()
+
+		// occupy the busy member
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h(context.Background(), "")
+		}()
+		waitFor(t, "busy member to take a request", func() bool { return p.Stats()[0].InFlight == 1 })
+		for range 10 {
+			if out, _ := h(context.Background(), ""); out != "idle:" {
+				t.Errorf("strategy %d, expected idle member, got: %s", strategy, out)
+			}
+		}
+		close(release)
+		wg.Wait()
+	}
+}
+
+func TestPoolEjection(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	p := NewPool(PoolOptions{EjectAfter: 2, EjectFor: time.Minute, Clock: clock})
+	p.Add("bad", failing(errTemporary, errTemporary, errTemporary))
+	p.Add("good", constant("good"))
+	h := p.Handler()
+	for range 4 {
+		h(context.Background(), "")
+	}
+	stats := p.Stats()
+	if stats[0].EjectedUntil != clock.Now().Add(time.Minute) {
+		t.Fatalf("expected bad member to be ejected, got: %+v", stats[0])
+	}
+	for range 3 {
+		if out, _ := h(context.Background(), ""); out != "good:" {
+			t.Errorf("expected ejected member to get no requests, got: %s", out)
+		}
+	}
+	clock.Advance(time.Minute)
+	h(context.Background(), "")
+	h(context.Background(), "")
+	if stats := p.Stats(); stats[0].Requests != 3 {
+		t.Errorf("expected bad member to be back after ejection time, got: %+v", stats[0])
+	}
+
+	p.SetHealthy("bad", false)
+	p.SetHealthy("good", false)
+	if _, err := h(context.Background(), ""); !errors.Is(err, ErrNoHealthyMembers) {
+		t.Errorf("expected ErrNoHealthyMembers, got: %v", err)
+	}
+}
+
+func TestPoolPanickingMember(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	p := NewPool(PoolOptions{EjectAfter: 1, EjectFor: time.Minute, Clock: clock})
+	p.Add("panicking", Wrap(notImplemented))
+	p.Add("good", constant("good"))
+	r := New(WithRecovery())
+	r.Handle("/svc", p.Handler())
+	var pe *PanicError
+	if _, err := r.Match(routing.Request{Path: "/svc"}); !errors.As(err, &pe) {
+		t.Fatalf("expected the first member to panic, got: %v", err)
+	}
+	if stats := p.Stats(); stats[0].Failures != 1 || stats[0].EjectedUntil.IsZero() {
+		t.Fatalf("expected panicking member to be counted as failed and ejected, got: %+v", stats[0])
+	}
+	runRouterTests(t, r, "ejected panicking member", "/svc", []test{
+		{"a", "good:a"},
+		{"b", "good:b"},
+	})
+}
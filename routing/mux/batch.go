@@ -0,0 +1,34 @@
+package mux
+
+import (
+	"context"
+	"sync"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// MatchAll matches a batch of requests with MatchResult and returns their results in the
+// order of reqs. Up to concurrency requests are matched at a time, one by one if it is
+// 1 or less. Errors do not stop the batch, each result carries its own.
+func (r *Router) MatchAll(ctx context.Context, reqs []routing.Request, concurrency int) []Result {
+	results := make([]Result, len(reqs))
+	if concurrency <= 1 {
+		for i, req := range reqs {
+			results[i] = r.MatchResult(ctx, req)
+		}
+		return results
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = r.MatchResult(ctx, req)
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,59 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestMatchAll(t *testing.T) {
+	defer checkLeaks(t)()
+	var running, peak atomic.Int32
+	r := New()
+	r.Handle("/svc", func(ctx context.Context, in string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// later requests finish first
+		time.Sleep(time.Duration(10-len(in)) * time.Millisecond)
+		return in + "!", nil
+	})
+	reqs := []routing.Request{
+		{Path: "/svc", Data: "a"},
+		{Path: "/nope", Data: "b"},
+		{Path: "/svc", Data: "cc"},
+		{Path: "/svc", Data: "ddd"},
+		{Path: "/svc", Data: "eeee"},
+	}
+	for _, concurrency := range []int{0, 1, 2, 10} {
+		peak.Store(0)
+		results := r.MatchAll(context.Background(), reqs, concurrency)
+		if len(results) != len(reqs) {
+			t.Fatalf("concurrency %d, expected %d results, got: %d", concurrency, len(reqs), len(results))
+		}
+		for i, res := range results {
+			if res.Request != reqs[i] || res.ID == "" {
+				t.Errorf("concurrency %d, expected result %d for %+v, got: %+v", concurrency, i, reqs[i], res)
+			}
+			if i == 1 {
+				if !errors.Is(res.Err, ErrNotFound) {
+					t.Errorf("concurrency %d, expected ErrNotFound, got: %v", concurrency, res.Err)
+				}
+			} else if res.Output != reqs[i].Data+"!" || res.Err != nil {
+				t.Errorf("concurrency %d, expected: %s!, got: %q, error: %v", concurrency, reqs[i].Data, res.Output, res.Err)
+			}
+		}
+		if limit := int32(max(concurrency, 1)); peak.Load() > limit {
+			t.Errorf("concurrency %d, expected at most %d requests at a time, got: %d", concurrency, limit, peak.Load())
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a circuit breaker that rejects requests.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is a state of a circuit breaker.
+type BreakerState int
+
+const (
+	// Closed breaker lets all requests through and counts consecutive failures.
+	Closed BreakerState = iota
+	// Open breaker rejects all requests until the cooldown passes.
+	Open
+	// HalfOpen breaker lets a limited number of trial requests through: if they succeed
+	// the breaker closes, if any of them fails it opens again.
+	HalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOptions configures a Breaker.
+type BreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker, at least 1
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before letting trial requests through
+	Cooldown time.Duration
+	// HalfOpenRequests is the number of successful trial requests needed to close the breaker, at least 1
+	HalfOpenRequests int
+	// OnStateChange, if set, is called on every transition. It is called with the breaker
+	// locked, so it must not call the breaker.
+	OnStateChange func(from, to BreakerState)
+	// Clock is SystemClock if not set
+	Clock Clock
+}
+
+// Breaker is a circuit breaker. It stops calling a handler that keeps failing, giving
+// it time to recover, and fails fast with ErrCircuitOpen in the meantime.
+type Breaker struct {
+	opts  BreakerOptions
+	clock Clock
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// trials is the number of trial requests let through in half-open state,
+	// successes is the number of them that succeeded
+	trials, successes int
+	// generation changes with every state change, so that results of requests let through
+	// in an earlier state are not counted in the current one
+	generation uint64
+}
+
+// NewBreaker creates a closed breaker.
+func NewBreaker(opts BreakerOptions) *Breaker {
+	opts.FailureThreshold = max(opts.FailureThreshold, 1)
+	opts.HalfOpenRequests = max(opts.HalfOpenRequests, 1)
+	return &Breaker{opts: opts, clock: clockOrSystem(opts.Clock)}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkCooldown()
+	return b.state
+}
+
+// setState must be called with b.mu held
+func (b *Breaker) setState(s BreakerState) {
+	if s == b.state {
+		return
+	}
+	from := b.state
+	b.state = s
+	b.generation++
+	b.failures, b.trials, b.successes = 0, 0, 0
+	if s == Open {
+		b.openedAt = b.clock.Now()
+	}
+	if b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(from, s)
+	}
+}
+
+// checkCooldown moves an open breaker to half-open once the cooldown has passed.
+// It must be called with b.mu held.
+func (b *Breaker) checkCooldown() {
+	if b.state == Open && !b.clock.Now().Before(b.openedAt.Add(b.opts.Cooldown)) {
+		b.setState(HalfOpen)
+	}
+}
+
+// allow reports whether a request can go through, and the generation to pass to done
+func (b *Breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkCooldown()
+	switch b.state {
+	case Open:
+		return 0, false
+	case HalfOpen:
+		if b.trials >= b.opts.HalfOpenRequests {
+			return 0, false
+		}
+		b.trials++
+	}
+	return b.generation, true
+}
+
+// done records the outcome of a request that was allowed in the given generation.
+// Outcomes of requests from an earlier generation are ignored.
+func (b *Breaker) done(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case Closed:
+		if err == nil {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.opts.FailureThreshold {
+			b.setState(Open)
+		}
+	case HalfOpen:
+		if err != nil {
+			b.setState(Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.opts.HalfOpenRequests {
+			b.setState(Closed)
+		}
+	}
+}
+
+// Middleware returns a middleware that guards the rest of the chain with the breaker.
+// All routes that use the returned middleware share the breaker state.
+func (b *Breaker) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (out string, err error) {
+			generation, ok := b.allow()
+			if !ok {
+				return "", ErrCircuitOpen
+			}
+			// a panic in the chain counts as a failure, otherwise a panicking trial
+			// would hold its half-open slot forever
+			defer func() { b.done(generation, err) }()
+			err = errPanicked
+			return next(ctx, in)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var transitions []string
+	b := NewBreaker(BreakerOptions{
+		FailureThreshold: 2,
+		Cooldown:         time.Second,
+		HalfOpenRequests: 2,
+		Clock:            clock,
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	failing := true
+	r := New()
+	r.Handle("/flaky", func(ctx context.Context, in string) (string, error) {
+		if failing {
+			return "", errors.New("backend is down")
+		}
+		return in, nil
+	})
+	r.Use("/flaky", b.Middleware())
+	match := func() error {
+		_, err := r.Match(routing.Request{Path: "/flaky"})
+		return err
+	}
+	expectState := func(s BreakerState) {
+		t.Helper()
+		if got := b.State(); got != s {
+			t.Errorf("expected state: %v, got: %v", s, got)
+		}
+	}
+
+	match()
+	expectState(Closed)
+	match()
+	expectState(Open)
+	if err := match(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected open breaker to reject, got: %v", err)
+	}
+
+	// a failed trial opens the breaker again
+	clock.Advance(time.Second)
+	expectState(HalfOpen)
+	if err := match(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected trial request to reach the handler, got: %v", err)
+	}
+	expectState(Open)
+
+	// enough successful trials close it
+	clock.Advance(time.Second)
+	failing = false
+	if err := match(); err != nil {
+		t.Errorf("expected first trial to pass, got: %v", err)
+	}
+	expectState(HalfOpen)
+	if err := match(); err != nil {
+		t.Errorf("expected second trial to pass, got: %v", err)
+	}
+	expectState(Closed)
+
+	expected := []string{
+		"closed->open", "open->half-open", "half-open->open",
+		"open->half-open", "half-open->closed",
+	}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected transitions: %v, got: %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("transition %d, expected: %s, got: %s", i, expected[i], transitions[i])
+		}
+	}
+}
+
+func TestBreakerHalfOpenLimit(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBreaker(BreakerOptions{Cooldown: time.Second, Clock: clock})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := New()
+	r.Handle("/slow", func(ctx context.Context, in string) (string, error) {
+		if in == "fail" {
+			return "", errors.New("fail")
+		}
+		started <- struct{}{}
+		<-release
+		return in, nil
+	})
+	r.Use("/slow", b.Middleware())
+	r.Match(routing.Request{Path: "/slow", Data: "fail"})
+	clock.Advance(time.Second)
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Match(routing.Request{Path: "/slow"})
+		done <- err
+	}()
+	<-started
+	// the only trial request is still running
+	if _, err := r.Match(routing.Request{Path: "/slow"}); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected second request in half-open state to be rejected, got: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("expected trial to pass, got: %v", err)
+	}
+	if s := b.State(); s != Closed {
+		t.Errorf("expected closed breaker, got: %v", s)
+	}
+}
+
+func TestBreakerStaleResults(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBreaker(BreakerOptions{Cooldown: time.Second, Clock: clock})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := New()
+	r.Handle("/svc", func(ctx context.Context, in string) (string, error) {
+		switch in {
+		case "fail":
+			return "", errTemporary
+		case "slow ok", "slow fail":
+			started <- struct{}{}
+			<-release
+			if in == "slow fail" {
+				return "", errTemporary
+			}
+		}
+		return in, nil
+	})
+	r.Use("/svc", b.Middleware())
+
+	// slow requests let through while closed finish once the breaker is half-open
+	done := make(chan struct{})
+	for _, in := range []string{"slow ok", "slow fail"} {
+		go func() {
+			r.Match(routing.Request{Path: "/svc", Data: in})
+			done <- struct{}{}
+		}()
+		<-started
+	}
+	r.Match(routing.Request{Path: "/svc", Data: "fail"})
+	clock.Advance(time.Second)
+	if s := b.State(); s != HalfOpen {
+		t.Fatalf("expected half-open breaker, got: %v", s)
+	}
+	close(release)
+	<-done
+	<-done
+	if s := b.State(); s != HalfOpen {
+		t.Errorf("expected results of stale requests to be ignored, got: %v", s)
+	}
+	if _, err := r.Match(routing.Request{Path: "/svc", Data: "ok"}); err != nil {
+		t.Errorf("expected the trial request to be let through, got: %v", err)
+	}
+	if s := b.State(); s != Closed {
+		t.Errorf("expected successful trial to close the breaker, got: %v", s)
+	}
+}
+
+func TestBreakerPanickingTrial(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBreaker(BreakerOptions{Cooldown: time.Second, Clock: clock})
+	r := New(WithRecovery())
+	r.Handle("/svc", failing(errTemporary))
+	r.Use("/svc", b.Middleware())
+	r.Handle("/panic", Wrap(notImplemented))
+	r.Use("/panic", b.Middleware())
+
+	r.Match(routing.Request{Path: "/svc"})
+	clock.Advance(time.Second)
+	var pe *PanicError
+	if _, err := r.Match(routing.Request{Path: "/panic"}); !errors.As(err, &pe) {
+		t.Fatalf("expected the trial to panic, got: %v", err)
+	}
+	if s := b.State(); s != Open {
+		t.Errorf("expected panicking trial to reopen the breaker, got: %v", s)
+	}
+	clock.Advance(time.Second)
+	if _, err := r.Match(routing.Request{Path: "/svc", Data: "a"}); err != nil {
+		t.Errorf("expected a new trial after the cooldown, got: %v", err)
+	}
+	if s := b.State(); s != Closed {
+		t.Errorf("expected successful trial to close the breaker, got: %v", s)
+	}
+}
@@ -0,0 +1,133 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Reply is the result of one of the handlers of a broadcast.
+type Reply struct {
+	// Index of the handler in the order they were given to Broadcast
+	Index  int
+	Output string
+	Err    error
+}
+
+// Reducer combines replies of n handlers into a single result. Replies come from the
+// channel in the order the handlers finish. A reducer may return before reading all
+// of them, handlers that are still running are cancelled then.
+type Reducer func(replies <-chan Reply, n int) (string, error)
+
+// Broadcast returns a handler that runs all the given handlers concurrently on its input,
+// and combines their results with reduce. Unlike composing handlers, every handler gets
+// the same input. Panics in the handlers are returned as PanicError replies.
+func Broadcast(reduce Reducer, hs ...Handler) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		// buffered, so that handlers can finish after the reducer returns
+		replies := make(chan Reply, len(hs))
+		for i, h := range hs {
+			go func() {
+				rep := Reply{Index: i}
+				defer func() { replies <- rep }()
+				defer recoverTo(&rep.Err)
+				rep.Output, rep.Err = h(ctx, in)
+			}()
+		}
+		return reduce(replies, len(hs))
+	}
+}
+
+// HandleBroadcast registers handlers that all run on every request to path, see Broadcast.
+func (r *Router) HandleBroadcast(path string, reduce Reducer, hs ...Handler) {
+	r.Handle(path, Broadcast(reduce, hs...))
+}
+
+// collect reads all replies and sorts them by handler index
+func collect(replies <-chan Reply, n int) []Reply {
+	all := make([]Reply, 0, n)
+	for range n {
+		all = append(all, <-replies)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Index < all[j].Index })
+	return all
+}
+
+// Concat joins outputs of all successful handlers with sep, in the order the handlers were
+// given. Failed handlers are skipped, and if all of them fail, their errors are returned.
+func Concat(sep string) Reducer {
+	return func(replies <-chan Reply, n int) (string, error) {
+		var outs []string
+		var errs []error
+		for _, rep := range collect(replies, n) {
+			if rep.Err != nil {
+				errs = append(errs, rep.Err)
+				continue
+			}
+			outs = append(outs, rep.Output)
+		}
+		if len(outs) == 0 && len(errs) > 0 {
+			return "", errors.Join(errs...)
+		}
+		return strings.Join(outs, sep), nil
+	}
+}
+
+// FirstSuccess returns the output of the handler that succeeds first, without waiting
+// for the others. If all handlers fail, their errors are returned.
+func FirstSuccess(replies <-chan Reply, n int) (string, error) {
+	var errs []error
+	for range n {
+		rep := <-replies
+		if rep.Err == nil {
+			return rep.Output, nil
+		}
+		errs = append(errs, rep.Err)
+	}
+	return "", errors.Join(errs...)
+}
+
+// ErrNoMajority is returned by the Majority reducer when no output is shared by more
+// than half of the handlers.
+var ErrNoMajority = errors.New("no majority among handler outputs")
+
+// Majority returns the output that more than half of the handlers agree on, as soon as
+// there is one. Failed handlers count as disagreeing.
+func Majority(replies <-chan Reply, n int) (string, error) {
+	votes := make(map[string]int)
+	for range n {
+		rep := <-replies
+		if rep.Err != nil {
+			continue
+		}
+		votes[rep.Output]++
+		if votes[rep.Output] > n/2 {
+			return rep.Output, nil
+		}
+	}
+	return "", ErrNoMajority
+}
+
+// AllOrError joins outputs of all handlers with sep, in the order the handlers were given,
+// if all of them succeed. Otherwise it returns the first error as soon as it happens.
+func AllOrError(sep string) Reducer {
+	return func(replies <-chan Reply, n int) (string, error) {
+		all := make([]Reply, 0, n)
+		for range n {
+			rep := <-replies
+			if rep.Err != nil {
+				return "", rep.Err
+			}
+			all = append(all, rep)
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Index < all[j].Index })
+		outs := make([]string, len(all))
+		for i, rep := range all {
+			outs[i] = rep.Output
+		}
+		return strings.Join(outs, sep), nil
+	}
+}
@@ -0,0 +1,98 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// delayed returns a handler that returns out or err after d, or gives up when cancelled
+func delayed(d time.Duration, out string, err error) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		select {
+		case <-time.After(d):
+			return out, err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	defer checkLeaks(t)()
+	tests := []struct {
+		name   string
+		reduce Reducer
+		hs     []Handler
+		out    string
+		err    error
+	}{
+		{
+			name:   "concat in handler order",
+			reduce: Concat(","),
+			hs:     []Handler{delayed(20*time.Millisecond, "a", nil), delayed(0, "b", nil), delayed(0, "", errTemporary)},
+			out:    "a,b",
+		},
+		{
+			name:   "concat all failed",
+			reduce: Concat(","),
+			hs:     []Handler{delayed(0, "", errTemporary), delayed(0, "", errPermanent)},
+			err:    errPermanent,
+		},
+		{
+			name:   "first success",
+			reduce: FirstSuccess,
+			hs:     []Handler{delayed(time.Hour, "slow", nil), delayed(10*time.Millisecond, "fast", nil), delayed(0, "", errTemporary)},
+			out:    "fast",
+		},
+		{
+			name:   "majority",
+			reduce: Majority,
+			hs:     []Handler{delayed(0, "x", nil), delayed(0, "y", nil), delayed(0, "x", nil)},
+			out:    "x",
+		},
+		{
+			name:   "no majority",
+			reduce: Majority,
+			hs:     []Handler{delayed(0, "x", nil), delayed(0, "y", nil), delayed(0, "", errTemporary)},
+			err:    ErrNoMajority,
+		},
+		{
+			name:   "all",
+			reduce: AllOrError("+"),
+			hs:     []Handler{delayed(10*time.Millisecond, "a", nil), delayed(0, "b", nil)},
+			out:    "a+b",
+		},
+		{
+			name:   "all or error",
+			reduce: AllOrError("+"),
+			hs:     []Handler{delayed(time.Hour, "a", nil), delayed(0, "", errTemporary)},
+			err:    errTemporary,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := New()
+			r.HandleBroadcast("/all", test.reduce, test.hs...)
+			start := time.Now()
+			out, err := r.Match(routing.Request{Path: "/all"})
+			if out != test.out || !errors.Is(err, test.err) {
+				t.Errorf("expected: %q, error: %v, got: %q, error: %v", test.out, test.err, out, err)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("expected reducer to return early, took: %v", d)
+			}
+		})
+	}
+}
+
+func TestBroadcastPanic(t *testing.T) {
+	r := New()
+	r.HandleBroadcast("/all", Concat(""), Wrap(notImplemented), Wrap(identity))
+	runRouterTests(t, r, "panicking handler is skipped", "/all", []test{
+		{"a", "a"},
+	})
+}
@@ -0,0 +1,96 @@
+package mux
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// Built-in handlers and middlewares, referenced by name from route configurations and tools.
+// They mirror the ones from the routing guide.
+
+func capitalize(in string) string {
+	r, size := utf8.DecodeRuneInString(in)
+	if size == 0 {
+		return in
+	}
+	return string(unicode.ToUpper(r)) + in[size:]
+}
+
+func reverse(in string) string {
+	runes := []rune(in)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+var builtinHandlers = map[string]routing.Handler{
+	"identity":   func(in string) string { return in },
+	"double":     func(in string) string { return in + in },
+	"bang":       func(in string) string { return in + "!" },
+	"capitalize": capitalize,
+	"reverse":    reverse,
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+}
+
+// pre turns a string transformation into a middleware that applies it before the handler
+func pre(f func(string) string) routing.Middleware {
+	return func(h routing.Handler) routing.Handler {
+		return func(in string) string {
+			return h(f(in))
+		}
+	}
+}
+
+var builtinMiddlewares = map[string]routing.Middleware{
+	"double":     pre(func(in string) string { return in + in }),
+	"bangify":    pre(func(in string) string { return in + "!" }),
+	"capitalize": pre(capitalize),
+	"reverse":    pre(reverse),
+	"upper":      pre(strings.ToUpper),
+	"lower":      pre(strings.ToLower),
+}
+
+// appendPrefix is the prefix of the parametrized appender middleware: "append:s" appends s
+// to the input before calling the handler.
+const appendPrefix = "append:"
+
+// LookupHandler returns a built-in handler by name.
+func LookupHandler(name string) (routing.Handler, bool) {
+	h, ok := builtinHandlers[name]
+	return h, ok
+}
+
+// LookupMiddleware returns a built-in middleware by name.
+func LookupMiddleware(name string) (routing.Middleware, bool) {
+	if s, ok := strings.CutPrefix(name, appendPrefix); ok {
+		return pre(func(in string) string { return in + s }), true
+	}
+	mw, ok := builtinMiddlewares[name]
+	return mw, ok
+}
+
+// HandlerNames returns names of all built-in handlers, sorted.
+func HandlerNames() []string {
+	return sortedKeys(builtinHandlers)
+}
+
+// MiddlewareNames returns names of all built-in middlewares, sorted.
+// The parametrized appender is listed by its prefix.
+func MiddlewareNames() []string {
+	return append(sortedKeys(builtinMiddlewares), appendPrefix)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBulkheadFull is returned when a route has no free execution slots and its wait queue is full.
+var ErrBulkheadFull = errors.New("too many concurrent requests")
+
+// BulkheadOptions configures a Bulkhead.
+type BulkheadOptions struct {
+	// MaxInFlight is the number of requests that can run at once on a route, at least 1
+	MaxInFlight int
+	// MaxQueue is the number of requests that can wait for a free slot,
+	// zero means requests are rejected right away when all slots are busy
+	MaxQueue int
+}
+
+// Bulkhead limits the number of requests running concurrently, separately for every route,
+// so that a slow route cannot take all the goroutines. Requests over the limit wait in
+// a queue, and when the queue is full they fail with ErrBulkheadFull. Waiting requests
+// give up when their context is done.
+type Bulkhead struct {
+	size, maxQueue int
+
+	mu           sync.Mutex
+	compartments map[string]*compartment
+}
+
+type compartment struct {
+	slots chan struct{}
+	// queued is protected by the mutex of the bulkhead
+	queued int
+}
+
+// NewBulkhead creates a bulkhead.
+func NewBulkhead(opts BulkheadOptions) *Bulkhead {
+	return &Bulkhead{
+		size:         max(opts.MaxInFlight, 1),
+		maxQueue:     opts.MaxQueue,
+		compartments: make(map[string]*compartment),
+	}
+}
+
+// compartment returns the compartment of route, creating it if needed. b.mu must be held.
+func (b *Bulkhead) compartment(route string) *compartment {
+	c, ok := b.compartments[route]
+	if !ok {
+		c = &compartment{slots: make(chan struct{}, b.size)}
+		b.compartments[route] = c
+	}
+	return c
+}
+
+// InFlight returns the number of requests running on route.
+func (b *Bulkhead) InFlight(route string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.compartment(route).slots)
+}
+
+// Queued returns the number of requests waiting for a slot on route.
+func (b *Bulkhead) Queued(route string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.compartment(route).queued
+}
+
+func (b *Bulkhead) acquire(ctx context.Context, c *compartment) error {
+	b.mu.Lock()
+	select {
+	case c.slots <- struct{}{}:
+		b.mu.Unlock()
+		return nil
+	default:
+	}
+	if c.queued >= b.maxQueue {
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	c.queued++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		c.queued--
+		b.mu.Unlock()
+	}()
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Middleware returns a middleware that runs the rest of the chain within the bulkhead.
+func (b *Bulkhead) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			b.mu.Lock()
+			c := b.compartment(RouteFrom(ctx))
+			b.mu.Unlock()
+			if err := b.acquire(ctx, c); err != nil {
+				return "", err
+			}
+			defer func() { <-c.slots }()
+			return next(ctx, in)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	defer checkLeaks(t)()
+	release := make(chan struct{})
+	b := NewBulkhead(BulkheadOptions{MaxInFlight: 1, MaxQueue: 1})
+	r := New(WithMiddleware(b.Middleware()))
+	r.Handle("/slow", func(ctx context.Context, in string) (string, error) {
+		<-release
+		return in, nil
+	})
+	r.RegisterHandler("/fast", identity)
+
+	results := make(chan error, 2)
+	match := func() {
+		_, err := r.Match(routing.Request{Path: "/slow"})
+		results <- err
+	}
+	go match()
+	waitFor(t, "first request to run", func() bool { return b.InFlight("/slow") == 1 })
+	go match()
+	waitFor(t, "second request to be queued", func() bool { return b.Queued("/slow") == 1 })
+
+	if _, err := r.Match(routing.Request{Path: "/slow"}); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("expected request over the limit to be rejected, got: %v", err)
+	}
+	runRouterTests(t, r, "other routes are not affected", "/fast", []test{
+		{"a", "a"},
+	})
+
+	close(release)
+	for range 2 {
+		if err := <-results; err != nil {
+			t.Errorf("expected running and queued requests to succeed, got: %v", err)
+		}
+	}
+	if n := b.InFlight("/slow"); n != 0 {
+		t.Errorf("expected all slots to be released, got %d in flight", n)
+	}
+}
+
+func TestBulkheadQueueCancelled(t *testing.T) {
+	defer checkLeaks(t)()
+	release := make(chan struct{})
+	b := NewBulkhead(BulkheadOptions{MaxInFlight: 1, MaxQueue: 1})
+	r := New()
+	r.Handle("/slow", func(ctx context.Context, in string) (string, error) {
+		<-release
+		return in, nil
+	})
+	r.Use("/slow", b.Middleware())
+	done := make(chan struct{})
+	go func() {
+		r.Match(routing.Request{Path: "/slow"})
+		close(done)
+	}()
+	waitFor(t, "first request to run", func() bool { return b.InFlight("/slow") == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.MatchContext(ctx, routing.Request{Path: "/slow"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected queued request to give up, got: %v", err)
+	}
+	if n := b.Queued("/slow"); n != 0 {
+		t.Errorf("expected empty queue, got: %d", n)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,190 @@
+package mux
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errPanicked is the result shared with waiting requests when the running one panicked
+var errPanicked = errors.New("handler panicked")
+
+// CacheOptions configures a Cache.
+type CacheOptions struct {
+	// Size is the maximum number of cached results, at least 1.
+	// The least recently used result is evicted when the cache is full.
+	Size int
+	// TTL is how long a result stays valid, forever if zero
+	TTL time.Duration
+	// Clock is SystemClock if not set
+	Clock Clock
+}
+
+// CacheStats are counters of a Cache.
+type CacheStats struct {
+	Hits, Misses, Evictions uint64
+	// Shared is the number of requests that waited for the result of an identical request
+	// running at the same time instead of running the handler, they are counted as misses too
+	Shared uint64
+}
+
+// Cache memoizes results of handlers by route and data. Since handlers are functions
+// of their input, repeating a request gives the same result. Errors are not cached.
+type Cache struct {
+	size  int
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	// lru has the most recently used entry in front
+	lru *list.List
+	// calls are requests that are running right now, identical requests wait for them
+	calls map[cacheKey]*call
+	stats CacheStats
+}
+
+type cacheKey struct {
+	route, data string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	out     string
+	expires time.Time
+}
+
+// call is a request running on behalf of identical ones that wait for its result
+type call struct {
+	done chan struct{}
+	out  string
+	err  error
+}
+
+// shared reports whether the finished call's result can be given to the requests that
+// waited for it. It can't if the call failed with a context error: that was the context
+// of the request that ran it, and the others may still be live, so they have to retry.
+func (cl *call) shared() bool {
+	return !errors.Is(cl.err, context.Canceled) && !errors.Is(cl.err, context.DeadlineExceeded)
+}
+
+// NewCache creates an empty cache.
+func NewCache(opts CacheOptions) *Cache {
+	return &Cache{
+		size:    max(opts.Size, 1),
+		ttl:     opts.TTL,
+		clock:   clockOrSystem(opts.Clock),
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+		calls:   make(map[cacheKey]*call),
+	}
+}
+
+// Stats returns the current counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Len returns the number of cached results, including expired ones that were not evicted yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// lookup returns a valid cached result. c.mu must be held.
+func (c *Cache) lookup(k cacheKey) (string, bool) {
+	el, ok := c.entries[k]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.ttl > 0 && !c.clock.Now().Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, k)
+		return "", false
+	}
+	c.lru.MoveToFront(el)
+	return e.out, true
+}
+
+// store adds a result to the cache, evicting the least recently used one if needed.
+// c.mu must be held.
+func (c *Cache) store(k cacheKey, out string) {
+	e := &cacheEntry{key: k, out: out, expires: c.clock.Now().Add(c.ttl)}
+	if el, ok := c.entries[k]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[k] = c.lru.PushFront(e)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// Middleware returns a middleware that serves repeated requests from the cache. Concurrent
+// identical requests run the rest of the chain once and share the result. If the request
+// that runs it is cancelled, the ones waiting for it try again.
+func (c *Cache) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			k := cacheKey{route: RouteFrom(ctx), data: in}
+			// a request is counted once, even if it waits again after a cancelled call
+			for retry := false; ; retry = true {
+				c.mu.Lock()
+				if out, ok := c.lookup(k); ok {
+					if !retry {
+						c.stats.Hits++
+					}
+					c.mu.Unlock()
+					return out, nil
+				}
+				if !retry {
+					c.stats.Misses++
+				}
+				cl, ok := c.calls[k]
+				if !ok {
+					break
+				}
+				if !retry {
+					c.stats.Shared++
+				}
+				c.mu.Unlock()
+				select {
+				case <-cl.done:
+					if cl.shared() {
+						return cl.out, cl.err
+					}
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+			}
+			// c.mu is held here
+			cl := &call{done: make(chan struct{})}
+			c.calls[k] = cl
+			c.mu.Unlock()
+
+			// the call must be finished even if the handler panics, so that waiters are released
+			defer func() {
+				c.mu.Lock()
+				delete(c.calls, k)
+				if cl.err == nil {
+					c.store(k, cl.out)
+				}
+				c.mu.Unlock()
+				close(cl.done)
+			}()
+			cl.err = errPanicked
+			cl.out, cl.err = next(ctx, in)
+			return cl.out, cl.err
+		}
+	}
+}
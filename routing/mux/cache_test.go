@@ -0,0 +1,162 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// counting returns a handler that doubles its input and counts its calls
+func counting(calls *atomic.Int32) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		calls.Add(1)
+		return in + in, nil
+	}
+}
+
+func TestCache(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewCache(CacheOptions{Size: 2, TTL: time.Minute, Clock: clock})
+	var calls atomic.Int32
+	r := New(WithMiddleware(c.Middleware()))
+	r.Handle("/a", counting(&calls))
+	r.Handle("/b", counting(&calls))
+
+	steps := []struct {
+		req   routing.Request
+		calls int32
+	}{
+		{routing.Request{Path: "/a", Data: "x"}, 1},
+		{routing.Request{Path: "/a", Data: "x"}, 1},
+		// same data on another route is another entry
+		{routing.Request{Path: "/b", Data: "x"}, 2},
+		// /a x is used more recently than /b x now, so /b x is evicted
+		{routing.Request{Path: "/a", Data: "x"}, 2},
+		{routing.Request{Path: "/a", Data: "y"}, 3},
+		{routing.Request{Path: "/a", Data: "x"}, 3},
+		{routing.Request{Path: "/b", Data: "x"}, 4},
+	}
+	for i, step := range steps {
+		res, err := r.Match(step.req)
+		if err != nil || res != step.req.Data+step.req.Data {
+			t.Errorf("step %d, unexpected result: %s, error: %v", i, res, err)
+		}
+		if n := calls.Load(); n != step.calls {
+			t.Errorf("step %d %+v, expected %d handler calls, got: %d", i, step.req, step.calls, n)
+		}
+	}
+	expected := CacheStats{Hits: 3, Misses: 4, Evictions: 2}
+	if s := c.Stats(); s != expected {
+		t.Errorf("expected stats: %+v, got: %+v", expected, s)
+	}
+
+	clock.Advance(time.Minute)
+	r.Match(routing.Request{Path: "/b", Data: "x"})
+	if n := calls.Load(); n != 5 {
+		t.Errorf("expected expired entry to be recomputed, got %d calls", n)
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	c := NewCache(CacheOptions{Size: 10})
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := New()
+	r.Handle("/slow", func(ctx context.Context, in string) (string, error) {
+		calls.Add(1)
+		<-release
+		return in, nil
+	})
+	r.Use("/slow", c.Middleware())
+
+	const n = 5
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res, err := r.Match(routing.Request{Path: "/slow", Data: "x"}); err != nil || res != "x" {
+				t.Errorf("unexpected result: %s, error: %v", res, err)
+			}
+		}()
+	}
+	waitFor(t, "identical requests to wait", func() bool { return c.Stats().Shared == n-1 })
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single handler call, got: %d", got)
+	}
+}
+
+func TestCacheSharedCancelled(t *testing.T) {
+	c := NewCache(CacheOptions{Size: 10})
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := New()
+	r.Handle("/slow", func(ctx context.Context, in string) (string, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+			return in, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	r.Use("/slow", c.Middleware())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := r.MatchContext(ctx, routing.Request{Path: "/slow", Data: "x"})
+		leader <- err
+	}()
+	waitFor(t, "the first request to run", func() bool { return calls.Load() == 1 })
+	waiter := make(chan string)
+	go func() {
+		out, err := r.Match(routing.Request{Path: "/slow", Data: "x"})
+		if err != nil {
+			t.Errorf("expected waiting request to succeed, got: %v", err)
+		}
+		waiter <- out
+	}()
+	waitFor(t, "identical request to wait", func() bool { return c.Stats().Shared == 1 })
+
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled request to fail, got: %v", err)
+	}
+	// the waiting request is still live, so it runs the handler itself
+	waitFor(t, "waiting request to retry", func() bool { return calls.Load() == 2 })
+	close(release)
+	if out := <-waiter; out != "x" {
+		t.Errorf("expected: x, got: %s", out)
+	}
+	if stats := c.Stats(); stats != (CacheStats{Misses: 2, Shared: 1}) {
+		t.Errorf("expected each request to be counted once, got: %+v", stats)
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	c := NewCache(CacheOptions{Size: 10})
+	r := New(WithRecovery())
+	r.Handle("/fail", failing(errTemporary))
+	r.Use("/fail", c.Middleware())
+	if _, err := r.Match(routing.Request{Path: "/fail"}); err != errTemporary {
+		t.Errorf("expected error, got: %v", err)
+	}
+	if _, err := r.Match(routing.Request{Path: "/fail"}); err != nil {
+		t.Errorf("expected error not to be cached, got: %v", err)
+	}
+
+	r.RegisterHandler("/stub", notImplemented)
+	r.Use("/stub", c.Middleware())
+	r.Match(routing.Request{Path: "/stub"})
+	if c.Len() != 1 {
+		t.Errorf("expected only the successful result to be cached, got %d entries", c.Len())
+	}
+}
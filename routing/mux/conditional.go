@@ -0,0 +1,71 @@
+package mux
+
+import (
+	"context"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// current returns the request as seen by a middleware: the original path, and data as
+// modified by the middlewares before it
+func current(ctx context.Context, in string) routing.Request {
+	req, _ := RequestFrom(ctx)
+	req.Data = in
+	return req
+}
+
+// When returns a middleware that applies mw only to requests that m matches, other requests
+// go straight to the rest of the chain. The matcher sees data as it is at this point of the chain.
+func When(m routing.Matcher, mw Middleware) Middleware {
+	return func(next Handler) Handler {
+		wrapped := mw(next)
+		return func(ctx context.Context, in string) (string, error) {
+			if m(current(ctx, in)) {
+				return wrapped(ctx, in)
+			}
+			return next(ctx, in)
+		}
+	}
+}
+
+// Case is a branch of Switch.
+type Case struct {
+	Match      routing.Matcher
+	Middleware Middleware
+}
+
+// Switch returns a middleware that applies the middleware of the first case that matches
+// the request, or def if none does. A nil def lets such requests go straight to the rest of the chain.
+func Switch(def Middleware, cases ...Case) Middleware {
+	return func(next Handler) Handler {
+		wrapped := make([]Handler, len(cases))
+		for i, c := range cases {
+			wrapped[i] = c.Middleware(next)
+		}
+		fallback := next
+		if def != nil {
+			fallback = def(next)
+		}
+		return func(ctx context.Context, in string) (string, error) {
+			req := current(ctx, in)
+			for i, c := range cases {
+				if c.Match(req) {
+					return wrapped[i](ctx, in)
+				}
+			}
+			return fallback(ctx, in)
+		}
+	}
+}
+
+// Not returns a matcher that matches requests m does not.
+func Not(m routing.Matcher) routing.Matcher {
+	return func(req routing.Request) bool {
+		return !m(req)
+	}
+}
+
+// NonEmpty matches requests with non-empty data.
+func NonEmpty(req routing.Request) bool {
+	return req.Data != ""
+}
@@ -0,0 +1,59 @@
+package mux
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func builtinMiddleware(t *testing.T, name string) Middleware {
+	mw, ok := LookupMiddleware(name)
+	if !ok {
+		t.Fatalf("no built-in middleware %s", name)
+	}
+	return WrapMiddleware(mw)
+}
+
+func TestWhen(t *testing.T) {
+	r := New()
+	r.RegisterHandler("/bang", identity)
+	r.Use("/bang", When(NonEmpty, builtinMiddleware(t, "bangify")))
+	runRouterTests(t, r, "bangify non-empty", "/bang", []test{
+		{"", ""},
+		{"a", "a!"},
+	})
+
+	// the matcher sees data changed by previous middlewares
+	r.RegisterHandler("/double", identity)
+	r.Use("/double", builtinMiddleware(t, "append:a"))
+	r.Use("/double", When(Not(NonEmpty), builtinMiddleware(t, "double")))
+	runRouterTests(t, r, "condition on modified data", "/double", []test{
+		{"", "a"},
+	})
+}
+
+func TestSwitch(t *testing.T) {
+	hasPrefix := func(p string) routing.Matcher {
+		return func(req routing.Request) bool { return strings.HasPrefix(req.Data, p) }
+	}
+	r := New()
+	r.RegisterHandler("/switch", identity)
+	r.Use("/switch", Switch(builtinMiddleware(t, "bangify"),
+		Case{hasPrefix("r"), builtinMiddleware(t, "reverse")},
+		Case{hasPrefix("c"), builtinMiddleware(t, "capitalize")},
+		Case{hasPrefix("re"), builtinMiddleware(t, "upper")},
+	))
+	runRouterTests(t, r, "first matching case", "/switch", []test{
+		{"rev", "ver"},
+		{"cap", "Cap"},
+		{"other", "other!"},
+	})
+
+	r.RegisterHandler("/nodefault", identity)
+	r.Use("/nodefault", Switch(nil, Case{hasPrefix("r"), builtinMiddleware(t, "reverse")}))
+	runRouterTests(t, r, "no default", "/nodefault", []test{
+		{"rev", "ver"},
+		{"other", "other"},
+	})
+}
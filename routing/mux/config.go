@@ -0,0 +1,71 @@
+package mux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Config describes a routing table in terms of built-in handlers and middlewares.
+// In JSON it looks like this:
+//
+//	{"routes": [
+//		{"path": "/revcap", "handler": "identity", "middleware": ["reverse", "capitalize"]}
+//	]}
+type Config struct {
+	Routes []RouteConfig `json:"routes"`
+}
+
+// RouteConfig is a single route of Config.
+type RouteConfig struct {
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+	// Middleware are applied in order, first one runs first
+	Middleware []string `json:"middleware,omitempty"`
+}
+
+// ReadConfig decodes a JSON configuration.
+func ReadConfig(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	return &c, nil
+}
+
+// LoadConfig reads a JSON configuration from a file.
+func LoadConfig(name string) (*Config, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Apply registers all routes of the configuration on r. Nothing is registered if
+// the configuration refers to unknown handlers or middlewares.
+func (c *Config) Apply(r *Router) error {
+	for _, rc := range c.Routes {
+		if _, ok := LookupHandler(rc.Handler); !ok {
+			return fmt.Errorf("route %q: unknown handler %q", rc.Path, rc.Handler)
+		}
+		for _, name := range rc.Middleware {
+			if _, ok := LookupMiddleware(name); !ok {
+				return fmt.Errorf("route %q: unknown middleware %q", rc.Path, name)
+			}
+		}
+	}
+	for _, rc := range c.Routes {
+		h, _ := LookupHandler(rc.Handler)
+		r.RegisterHandler(rc.Path, h)
+		for _, name := range rc.Middleware {
+			mw, _ := LookupMiddleware(name)
+			r.UseMiddleware(rc.Path, mw)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mux
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	conf := `{"routes": [
+		{"path": "/shout", "handler": "upper", "middleware": ["append:?", "bangify"]},
+		{"path": "/rev", "handler": "reverse"}
+	]}`
+	c, err := ReadConfig(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	if err := c.Apply(r); err != nil {
+		t.Fatal(err)
+	}
+	runRouterTests(t, r, "configured middlewares", "/shout", []test{
+		{"abc", "ABC?!"},
+	})
+	runRouterTests(t, r, "configured handler", "/rev", []test{
+		{"abc", "cba"},
+	})
+}
+
+func TestConfigErrors(t *testing.T) {
+	confs := []string{
+		`{"routes": [{"path": "/a", "handler": "nope"}]}`,
+		`{"routes": [{"path": "/a", "handler": "identity", "middleware": ["nope"]}]}`,
+	}
+	for _, conf := range confs {
+		c, err := ReadConfig(strings.NewReader(conf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := New()
+		if err := c.Apply(r); err == nil {
+			t.Errorf("config: %s, expected error", conf)
+		}
+		if paths := r.Paths(); len(paths) != 0 {
+			t.Errorf("config: %s, expected no routes registered, got: %v", conf, paths)
+		}
+	}
+	if _, err := ReadConfig(strings.NewReader(`{"rutes": []}`)); err == nil {
+		t.Errorf("expected error on unknown field")
+	}
+}
@@ -0,0 +1,102 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// rule routes requests to a path by their content
+type rule struct {
+	name    string
+	match   routing.Matcher
+	handler Handler
+}
+
+type compiledRule struct {
+	rule
+	chain Handler
+}
+
+// HandleWhen registers h for requests to path that m matches, which makes it possible to
+// route requests to a single path by their data. Matchers see the request as it came,
+// before any middleware. Rules of a path are tried in the order they were registered, and
+// the first one that matches wins. If none matches, the handler registered with Handle
+// is used, and if there is none, Match returns ErrNotFound.
+// All middlewares of the path apply to handlers of rules as well. The name identifies the
+// rule in metrics, logs and traces, see RouteFrom. Registering a rule with an existing
+// name replaces it, keeping its position.
+func (r *Router) HandleWhen(path, name string, m routing.Matcher, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt := r.route(path)
+	ru := rule{name: name, match: m, handler: h}
+	replaced := false
+	for i := range rt.rules {
+		if rt.rules[i].name == name {
+			rt.rules[i] = ru
+			replaced = true
+		}
+	}
+	if !replaced {
+		rt.rules = append(rt.rules, ru)
+	}
+	r.compile(rt)
+}
+
+// DataPrefix matches requests with data starting with prefix.
+func DataPrefix(prefix string) routing.Matcher {
+	return func(req routing.Request) bool {
+		return strings.HasPrefix(req.Data, prefix)
+	}
+}
+
+// DataLength matches requests with data of min to max characters, inclusive.
+// A negative max means there is no upper bound.
+func DataLength(min, max int) routing.Matcher {
+	return func(req routing.Request) bool {
+		n := utf8.RuneCountInString(req.Data)
+		return n >= min && (max < 0 || n <= max)
+	}
+}
+
+// Extracted matches requests for which extract returns value. Use it to route by a part
+// of data in a format of your own.
+func Extracted(extract func(data string) string, value string) routing.Matcher {
+	return func(req routing.Request) bool {
+		return extract(req.Data) == value
+	}
+}
+
+// JSONField matches requests with data that is a JSON object with the given field equal
+// to value. Nested fields are separated with dots, like "user.role". String fields are
+// compared with value as is, other fields by their JSON encoding, like "true" or "42".
+// Requests with data that is not JSON never match.
+func JSONField(field, value string) routing.Matcher {
+	path := strings.Split(field, ".")
+	return func(req routing.Request) bool {
+		var v any
+		dec := json.NewDecoder(strings.NewReader(req.Data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return false
+		}
+		for _, name := range path {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return false
+			}
+			if v, ok = obj[name]; !ok {
+				return false
+			}
+		}
+		if s, ok := v.(string); ok {
+			return s == value
+		}
+		b, err := json.Marshal(v)
+		return err == nil && bytes.Equal(b, []byte(value))
+	}
+}
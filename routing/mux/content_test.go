@@ -0,0 +1,98 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// constant returns a handler that prefixes its input with s
+func constant(s string) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		return s + ":" + in, nil
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       routing.Matcher
+		data    string
+		matches bool
+	}{
+		{"prefix", DataPrefix("ab"), "abc", true},
+		{"prefix", DataPrefix("ab"), "cab", false},
+		{"length", DataLength(1, 2), "ü", true},
+		{"length", DataLength(1, 2), "abc", false},
+		{"length", DataLength(1, -1), "", false},
+		{"extracted", Extracted(func(s string) string { return strings.ToLower(s) }, "x"), "X", true},
+		{"json string", JSONField("kind", "a"), `{"kind": "a"}`, true},
+		{"json nested", JSONField("user.role", "admin"), `{"user": {"role": "admin"}}`, true},
+		{"json number", JSONField("n", "42"), `{"n": 42}`, true},
+		{"json bool", JSONField("ok", "true"), `{"ok": true}`, true},
+		{"json mismatch", JSONField("kind", "a"), `{"kind": "b"}`, false},
+		{"json missing", JSONField("user.role", "a"), `{"user": "a"}`, false},
+		{"not json", JSONField("kind", "a"), `kind: a`, false},
+	}
+	for _, test := range tests {
+		if got := test.m(routing.Request{Data: test.data}); got != test.matches {
+			t.Errorf("%s on data %s, expected match: %v, got: %v", test.name, test.data, test.matches, got)
+		}
+	}
+}
+
+func TestHandleWhen(t *testing.T) {
+	var routes []string
+	r := New(WithHook(func(info MatchInfo) { routes = append(routes, info.Route) }))
+	bang, _ := LookupMiddleware("bangify")
+	r.UseMiddleware("/orders", bang)
+	r.HandleWhen("/orders", "refund", JSONField("kind", "refund"), constant("refund"))
+	r.HandleWhen("/orders", "big", DataLength(20, -1), constant("big"))
+	r.Handle("/orders", constant("default"))
+
+	tests := []test{
+		// rules are tried in order of registration, and see the data before middlewares
+		{`{"kind": "refund", "amount": 100}`, `refund:{"kind": "refund", "amount": 100}!`},
+		{`{"kind": "buy", "amount": 100}`, `big:{"kind": "buy", "amount": 100}!`},
+		{`{}`, `default:{}!`},
+	}
+	runRouterTests(t, r, "content routing", "/orders", tests)
+	expected := []string{"/orders refund", "/orders big", "/orders"}
+	for i, route := range expected {
+		if routes[i] != route {
+			t.Errorf("request %d, expected route: %s, got: %s", i, route, routes[i])
+		}
+	}
+
+	// replacing a rule keeps its precedence
+	r.HandleWhen("/orders", "refund", DataPrefix("{"), constant("any"))
+	runRouterTests(t, r, "replaced rule", "/orders", []test{
+		{`{"kind": "buy", "amount": 100}`, `any:{"kind": "buy", "amount": 100}!`},
+	})
+}
+
+func TestHandleWhenWithoutDefault(t *testing.T) {
+	r := New()
+	r.HandleWhen("/rules", "a", DataPrefix("a"), constant("a"))
+	runRouterTests(t, r, "matching rule", "/rules", []test{
+		{"ab", "a:ab"},
+	})
+	if _, err := r.Match(routing.Request{Path: "/rules", Data: "b"}); err == nil {
+		t.Errorf("expected error when no rule matches and there is no default handler")
+	}
+}
+
+func TestHandleWhenPanickingMatcher(t *testing.T) {
+	r := New(WithRecovery())
+	r.HandleWhen("/matcher", "bad", func(routing.Request) bool { panic("bad matcher") }, constant("bad"))
+	r.HandleWhen("/extractor", "bad", Extracted(func(string) string { panic("bad extractor") }, "x"), constant("x"))
+	for path, value := range map[string]string{"/matcher": "bad matcher", "/extractor": "bad extractor"} {
+		var pe *PanicError
+		if _, err := r.Match(routing.Request{Path: path, Data: "a"}); !errors.As(err, &pe) || pe.Value != value {
+			t.Errorf("path: %s, expected PanicError: %s, got: %v", path, value, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package mux
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// DeadLetter is a request that matched no route or whose handler failed.
+type DeadLetter struct {
+	Path string `json:"path"`
+	Data string `json:"data"`
+	// RequestID is the ID of the request, empty if it has none, see RequestIDFrom
+	RequestID string `json:"request_id,omitempty"`
+	Error     string `json:"error"`
+	// NotFound is true if the request matched no route
+	NotFound bool      `json:"not_found,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Request returns the dead-lettered request, to be matched again.
+func (d DeadLetter) Request() routing.Request {
+	return routing.Request{Path: d.Path, Data: d.Data}
+}
+
+// DeadLetterSink stores dead letters. Implementations must be safe for concurrent use.
+type DeadLetterSink interface {
+	Put(d DeadLetter) error
+}
+
+// DeadLetterOptions configures WithDeadLetters.
+type DeadLetterOptions struct {
+	// Clock stamps the letters, SystemClock if not set
+	Clock Clock
+	// OnError is called when the sink fails to store a letter, the error is dropped if nil
+	OnError func(error)
+}
+
+// WithDeadLetters puts every request that matched no route or failed into sink.
+// Letters are put synchronously, after the request is matched.
+func WithDeadLetters(sink DeadLetterSink, opts DeadLetterOptions) Option {
+	clock := clockOrSystem(opts.Clock)
+	return WithHook(func(info MatchInfo) {
+		if info.Err == nil {
+			return
+		}
+		err := sink.Put(DeadLetter{
+			Path:      info.Request.Path,
+			Data:      info.Request.Data,
+			RequestID: info.RequestID,
+			Error:     info.Err.Error(),
+			NotFound:  errors.Is(info.Err, ErrNotFound),
+			Time:      clock.Now(),
+		})
+		if err != nil && opts.OnError != nil {
+			opts.OnError(err)
+		}
+	})
+}
+
+// DeadLetterRing is a DeadLetterSink that keeps the latest letters in memory.
+type DeadLetterRing struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+	// next is the index of the next letter to overwrite once the ring is full
+	next int
+}
+
+// NewDeadLetterRing creates a ring that keeps up to size letters, dropping the oldest
+// ones when it is full. Size must be positive.
+func NewDeadLetterRing(size int) *DeadLetterRing {
+	if size <= 0 {
+		panic("mux: dead letter ring size must be positive")
+	}
+	return &DeadLetterRing{letters: make([]DeadLetter, 0, size)}
+}
+
+// Put implements DeadLetterSink.
+func (r *DeadLetterRing) Put(d DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.letters) < cap(r.letters) {
+		r.letters = append(r.letters, d)
+		return nil
+	}
+	r.letters[r.next] = d
+	r.next = (r.next + 1) % len(r.letters)
+	return nil
+}
+
+// Letters returns the letters in the ring, oldest first.
+func (r *DeadLetterRing) Letters() []DeadLetter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	letters := make([]DeadLetter, 0, len(r.letters))
+	letters = append(letters, r.letters[r.next:]...)
+	return append(letters, r.letters[:r.next]...)
+}
+
+// DeadLetterFile is a DeadLetterSink that appends letters to a file as JSON lines.
+// Read them back with ReadDeadLetters.
+type DeadLetterFile struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenDeadLetterFile opens the named file for appending, creating it if needed.
+func OpenDeadLetterFile(name string) (*DeadLetterFile, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterFile{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Put implements DeadLetterSink.
+func (s *DeadLetterFile) Put(d DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(d)
+}
+
+// Close closes the file.
+func (s *DeadLetterFile) Close() error {
+	return s.f.Close()
+}
+
+// ReadDeadLetters reads letters written by a DeadLetterFile or WriteDeadLetters.
+func ReadDeadLetters(r io.Reader) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for line := 1; sc.Scan(); line++ {
+		var d DeadLetter
+		if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		letters = append(letters, d)
+	}
+	return letters, sc.Err()
+}
+
+// WriteDeadLetters writes letters in the format of DeadLetterFile.
+func WriteDeadLetters(w io.Writer, letters []DeadLetter) error {
+	enc := json.NewEncoder(w)
+	for _, d := range letters {
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestDeadLetters(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ring := NewDeadLetterRing(2)
+	r := New(WithDeadLetters(ring, DeadLetterOptions{Clock: clock}))
+	r.Handle("/fail", failing(errTemporary, errPermanent))
+	r.RegisterHandler("/ok", identity)
+
+	r.Match(routing.Request{Path: "/ok", Data: "a"})
+	r.Match(routing.Request{Path: "/fail", Data: "b"})
+	clock.Advance(time.Second)
+	r.Match(routing.Request{Path: "/nope", Data: "c"})
+	clock.Advance(time.Second)
+	r.Match(routing.Request{Path: "/fail", Data: "d"})
+
+	// the oldest letter, for "b", is dropped
+	expected := []DeadLetter{
+		{Path: "/nope", Data: "c", Error: `no handler registered for path "/nope"`, NotFound: true, Time: time.Unix(1, 0)},
+		{Path: "/fail", Data: "d", Error: errPermanent.Error(), Time: time.Unix(2, 0)},
+	}
+	letters := ring.Letters()
+	if len(letters) != len(expected) {
+		t.Fatalf("expected %d letters, got: %+v", len(expected), letters)
+	}
+	for i, exp := range expected {
+		if letters[i] != exp {
+			t.Errorf("letter %d, expected: %+v, got: %+v", i, exp, letters[i])
+		}
+	}
+	if req := letters[1].Request(); req != (routing.Request{Path: "/fail", Data: "d"}) {
+		t.Errorf("expected request to replay, got: %+v", req)
+	}
+}
+
+func TestDeadLetterFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "dead.jsonl")
+	for range 2 {
+		// letters are appended across reopens
+		f, err := OpenDeadLetterFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := New(WithDeadLetters(f, DeadLetterOptions{}))
+		r.MatchResult(ContextWithRequestID(t.Context(), "id"), routing.Request{Path: "/nope", Data: "x"})
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letters, err := ReadDeadLetters(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 || letters[0].RequestID != "id" || !letters[1].NotFound {
+		t.Errorf("expected two letters for the unmatched request, got: %+v", letters)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDeadLetters(&buf, letters); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(b) {
+		t.Errorf("expected letters to be written back as read:\n%s\ngot:\n%s", b, buf.String())
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Put(DeadLetter) error {
+	return errPermanent
+}
+
+func TestDeadLetterSinkError(t *testing.T) {
+	var got error
+	r := New(WithDeadLetters(failingSink{}, DeadLetterOptions{OnError: func(err error) { got = err }}))
+	r.Match(routing.Request{Path: "/nope"})
+	if !errors.Is(got, errPermanent) {
+		t.Errorf("expected sink error to be reported, got: %v", got)
+	}
+}
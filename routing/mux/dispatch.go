@@ -0,0 +1,118 @@
+package mux
+
+import (
+	"context"
+	"sync"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// DispatchOptions configures Router.Dispatch.
+type DispatchOptions struct {
+	// Workers is the number of requests matched concurrently, 1 if zero
+	Workers int
+	// Ordered delivers results in the order requests were received, rather than as
+	// they complete. A slow request then holds back the results of the ones after it
+	Ordered bool
+	// Buffer is the capacity of the results channel
+	Buffer int
+}
+
+// job is a request taken by Dispatch, slot receives its result in ordered mode
+type job struct {
+	req  routing.Request
+	slot chan Result
+}
+
+// Dispatch matches requests received from reqs on a pool of workers, with MatchResult,
+// and sends the results to the returned channel.
+//
+// When results are not consumed, workers wait for the consumer and stop taking requests,
+// so senders to reqs block as well. To shut down gracefully, close reqs: the requests
+// already taken are matched and delivered, then the results channel is closed.
+// Cancelling ctx aborts instead: no more requests are taken, requests in flight see the
+// cancelled context, results not delivered yet are dropped and the channel is closed.
+func (r *Router) Dispatch(ctx context.Context, reqs <-chan routing.Request, opts DispatchOptions) <-chan Result {
+	workers := max(opts.Workers, 1)
+	out := make(chan Result, opts.Buffer)
+	jobs := make(chan job)
+	// slots are the result slots of the requests in the order they were received,
+	// its capacity limits how far workers get ahead of the slowest request
+	var slots chan chan Result
+	if opts.Ordered {
+		slots = make(chan chan Result, workers)
+	}
+
+	go func() {
+		defer close(jobs)
+		if slots != nil {
+			defer close(slots)
+		}
+		for {
+			var j job
+			select {
+			case req, ok := <-reqs:
+				if !ok {
+					return
+				}
+				j.req = req
+			case <-ctx.Done():
+				return
+			}
+			if slots != nil {
+				j.slot = make(chan Result, 1)
+				select {
+				case slots <- j.slot:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := r.MatchResult(ctx, j.req)
+				if j.slot != nil {
+					j.slot <- res
+					continue
+				}
+				select {
+				case out <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		defer wg.Wait()
+		if slots == nil {
+			return
+		}
+		for slot := range slots {
+			select {
+			case res := <-slot:
+				select {
+				case out <- res:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
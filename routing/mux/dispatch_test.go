@@ -0,0 +1,112 @@
+package mux
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// send sends requests with the given data to path on a new channel, and closes it
+func send(path string, data ...string) <-chan routing.Request {
+	reqs := make(chan routing.Request)
+	go func() {
+		defer close(reqs)
+		for _, d := range data {
+			reqs <- routing.Request{Path: path, Data: d}
+		}
+	}()
+	return reqs
+}
+
+func TestDispatch(t *testing.T) {
+	defer checkLeaks(t)()
+	r := New()
+	// "slow" takes a while, so the others complete before it
+	r.Handle("/svc", func(ctx context.Context, in string) (string, error) {
+		if in == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return in, nil
+	})
+	tests := []struct {
+		ordered  bool
+		expected string
+	}{
+		{true, "slow,a,b,c"},
+		{false, "a,b,c,slow"},
+	}
+	for _, test := range tests {
+		results := r.Dispatch(context.Background(), send("/svc", "slow", "a", "b", "c"), DispatchOptions{
+			Workers: 2,
+			Ordered: test.ordered,
+		})
+		var outs []string
+		for res := range results {
+			if res.Err != nil || res.ID == "" {
+				t.Errorf("expected result with an ID, got: %+v", res)
+			}
+			outs = append(outs, res.Output)
+		}
+		if got := strings.Join(outs, ","); got != test.expected {
+			t.Errorf("ordered: %v, expected: %s, got: %s", test.ordered, test.expected, got)
+		}
+	}
+}
+
+func TestDispatchBackpressure(t *testing.T) {
+	defer checkLeaks(t)()
+	for _, ordered := range []bool{true, false} {
+		var calls atomic.Int32
+		r := New()
+		r.Handle("/svc", counting(&calls))
+		reqs := make(chan routing.Request)
+		results := r.Dispatch(context.Background(), reqs, DispatchOptions{Workers: 2, Ordered: ordered, Buffer: 1})
+
+		// nobody reads results, so sending blocks once workers and buffers are full
+		sent := 0
+	send:
+		for {
+			select {
+			case reqs <- routing.Request{Path: "/svc"}:
+				sent++
+			case <-time.After(20 * time.Millisecond):
+				break send
+			}
+		}
+		if sent > 10 {
+			t.Errorf("ordered: %v, expected senders to block, sent: %d", ordered, sent)
+		}
+
+		// graceful shutdown delivers every request taken
+		close(reqs)
+		got := 0
+		for range results {
+			got++
+		}
+		if got != sent || int(calls.Load()) != sent {
+			t.Errorf("ordered: %v, expected %d results, got: %d, calls: %d", ordered, sent, got, calls.Load())
+		}
+	}
+}
+
+func TestDispatchCancelled(t *testing.T) {
+	defer checkLeaks(t)()
+	for _, ordered := range []bool{true, false} {
+		r := New()
+		r.Handle("/slow", delayed(time.Hour, "slow", nil))
+		ctx, cancel := context.WithCancel(context.Background())
+		reqs := make(chan routing.Request)
+		results := r.Dispatch(ctx, reqs, DispatchOptions{Workers: 2, Ordered: ordered})
+		reqs <- routing.Request{Path: "/slow"}
+		cancel()
+		for res := range results {
+			if res.Err == nil {
+				t.Errorf("ordered: %v, expected cancelled request, got: %+v", ordered, res)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// BranchStatus tells how a branch of a scatter-gather ended.
+type BranchStatus string
+
+const (
+	BranchOK       BranchStatus = "ok"
+	BranchFailed   BranchStatus = "failed"
+	BranchTimedOut BranchStatus = "timed out"
+)
+
+// Branch is the outcome of a single sub-request of a scatter-gather.
+type Branch struct {
+	Path   string       `json:"path"`
+	Status BranchStatus `json:"status"`
+	Output string       `json:"output,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	// Duration is how long the branch took, or the time waited for it if it timed out
+	Duration time.Duration `json:"duration"`
+}
+
+// GatherReport is the result of a scatter-gather.
+type GatherReport struct {
+	// Branches are in the order the paths were given
+	Branches []Branch `json:"branches"`
+}
+
+// Completed returns the branches that succeeded.
+func (g *GatherReport) Completed() []Branch {
+	var bs []Branch
+	for _, b := range g.Branches {
+		if b.Status == BranchOK {
+			bs = append(bs, b)
+		}
+	}
+	return bs
+}
+
+// Gather sends in to all the given paths of the router concurrently, and waits for them
+// until timeout passes or ctx is done. Branches that have not finished by then are
+// reported as timed out and cancelled.
+func (r *Router) Gather(ctx context.Context, in string, timeout time.Duration, paths ...string) *GatherReport {
+	start := time.Now()
+	report := &GatherReport{Branches: make([]Branch, len(paths))}
+	hs := make([]Handler, len(paths))
+	for i, path := range paths {
+		report.Branches[i] = Branch{Path: path, Status: BranchTimedOut}
+		hs[i] = func(ctx context.Context, in string) (string, error) {
+			return r.MatchContext(ctx, routing.Request{Path: path, Data: in})
+		}
+	}
+	gather := func(replies <-chan Reply, n int) (string, error) {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for range n {
+			select {
+			case rep := <-replies:
+				if ctx.Err() != nil {
+					// the branch may have failed because it was cancelled
+					return "", nil
+				}
+				b := &report.Branches[rep.Index]
+				b.Duration = time.Since(start)
+				if rep.Err != nil {
+					b.Status, b.Error = BranchFailed, rep.Err.Error()
+				} else {
+					b.Status, b.Output = BranchOK, rep.Output
+				}
+			case <-timer.C:
+				return "", nil
+			case <-ctx.Done():
+				return "", nil
+			}
+		}
+		return "", nil
+	}
+	Broadcast(gather, hs...)(ctx, in)
+	waited := time.Since(start)
+	for i := range report.Branches {
+		if b := &report.Branches[i]; b.Status == BranchTimedOut {
+			b.Duration = waited
+		}
+	}
+	return report
+}
+
+// ScatterGather returns a handler that gathers results of the given paths of the router
+// with Router.Gather, and returns the report encoded as JSON. The handler succeeds even
+// if some or all branches fail, the report tells which ones.
+func ScatterGather(r *Router, timeout time.Duration, paths ...string) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		b, err := json.Marshal(r.Gather(ctx, in, timeout, paths...))
+		return string(b), err
+	}
+}
@@ -0,0 +1,63 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestGather(t *testing.T) {
+	defer checkLeaks(t)()
+	r := New()
+	r.RegisterHandler("/upper", func(s string) string { return s + "!" })
+	r.Handle("/slow", delayed(time.Hour, "slow", nil))
+	r.Handle("/fail", failing(errTemporary))
+	r.Handle("/all", ScatterGather(r, 20*time.Millisecond, "/upper", "/slow", "/fail", "/nope"))
+
+	start := time.Now()
+	out, err := r.Match(routing.Request{Path: "/all", Data: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected gather to stop at the deadline, took: %v", d)
+	}
+	var report GatherReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("expected JSON report, got: %s", out)
+	}
+	expected := []Branch{
+		{Path: "/upper", Status: BranchOK, Output: "a!"},
+		{Path: "/slow", Status: BranchTimedOut},
+		{Path: "/fail", Status: BranchFailed, Error: errTemporary.Error()},
+		{Path: "/nope", Status: BranchFailed, Error: `no handler registered for path "/nope"`},
+	}
+	if len(report.Branches) != len(expected) {
+		t.Fatalf("expected %d branches, got: %+v", len(expected), report.Branches)
+	}
+	for i, exp := range expected {
+		b := report.Branches[i]
+		b.Duration = 0
+		if b != exp {
+			t.Errorf("branch %d, expected: %+v, got: %+v", i, exp, b)
+		}
+	}
+	if slow := report.Branches[1]; slow.Duration < 20*time.Millisecond {
+		t.Errorf("expected timed out branch to report the time waited, got: %v", slow.Duration)
+	}
+}
+
+func TestGatherCancelled(t *testing.T) {
+	defer checkLeaks(t)()
+	r := New()
+	r.Handle("/slow", delayed(time.Hour, "slow", nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := r.Gather(ctx, "a", time.Hour, "/slow")
+	if report.Branches[0].Status != BranchTimedOut || len(report.Completed()) != 0 {
+		t.Errorf("expected cancelled branch to be reported as timed out, got: %+v", report.Branches)
+	}
+}
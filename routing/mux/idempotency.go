@@ -0,0 +1,197 @@
+package mux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// IdempotencyRecord is the stored result of a request with an idempotency key.
+type IdempotencyRecord struct {
+	Key     string    `json:"key"`
+	Output  string    `json:"output"`
+	Created time.Time `json:"created"`
+}
+
+// IdempotencyStore keeps results of requests by their idempotency keys.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the latest record with the given key
+	Get(key string) (IdempotencyRecord, bool, error)
+	// Put saves a record, replacing the previous one with the same key
+	Put(rec IdempotencyRecord) error
+}
+
+// MemoryStore is an IdempotencyStore that keeps records in memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]IdempotencyRecord)}
+}
+
+// Get implements IdempotencyStore.
+func (s *MemoryStore) Get(key string) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[key]
+	return rec, ok, nil
+}
+
+// Put implements IdempotencyStore.
+func (s *MemoryStore) Put(rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Key] = rec
+	return nil
+}
+
+// FileStore is an IdempotencyStore backed by an append-only file of JSON lines, so that
+// records survive restarts. All records are kept in memory as well.
+type FileStore struct {
+	mem *MemoryStore
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenFileStore opens the named file, creating it if needed, and loads records from it.
+func OpenFileStore(name string) (*FileStore, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{mem: NewMemoryStore(), f: f, enc: json.NewEncoder(f)}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for line := 1; sc.Scan(); line++ {
+		var rec IdempotencyRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		s.mem.Put(rec)
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get implements IdempotencyStore.
+func (s *FileStore) Get(key string) (IdempotencyRecord, bool, error) {
+	return s.mem.Get(key)
+}
+
+// Put implements IdempotencyStore.
+func (s *FileStore) Put(rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(rec); err != nil {
+		return err
+	}
+	return s.mem.Put(rec)
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	return s.f.Close()
+}
+
+// IdempotencyOptions configures the Idempotency middleware.
+type IdempotencyOptions struct {
+	// Key returns the idempotency key of a request, requests with an empty key are not
+	// deduplicated. Keys are global, use ByRouteAndKey to scope them to a route.
+	// Key is required.
+	Key KeyFunc
+	// Store keeps results, a new MemoryStore if not set
+	Store IdempotencyStore
+	// Window is how long a result is replayed, forever if zero
+	Window time.Duration
+	// Clock is SystemClock if not set
+	Clock Clock
+}
+
+// Idempotency returns a middleware that runs the rest of the chain only once for requests
+// with the same idempotency key, and replays the stored result for repeated requests.
+// Only successful results are stored, so a failed request can be repeated with the same key.
+// A request that comes while another one with the same key is running waits for it and
+// gets its result, error included, unless that request was cancelled: then it runs
+// the chain itself.
+func Idempotency(opts IdempotencyOptions) Middleware {
+	if opts.Key == nil {
+		panic("mux: idempotency key function is required")
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	clock := clockOrSystem(opts.Clock)
+	var mu sync.Mutex
+	calls := make(map[string]*call)
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			req, _ := RequestFrom(ctx)
+			key := opts.Key(ctx, req)
+			if key == "" {
+				return next(ctx, in)
+			}
+
+			mu.Lock()
+			for {
+				cl, ok := calls[key]
+				if !ok {
+					break
+				}
+				mu.Unlock()
+				select {
+				case <-cl.done:
+					if cl.shared() {
+						return cl.out, cl.err
+					}
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+				mu.Lock()
+			}
+			rec, ok, err := store.Get(key)
+			if err != nil {
+				mu.Unlock()
+				return "", fmt.Errorf("idempotency store: %w", err)
+			}
+			if ok && (opts.Window == 0 || clock.Now().Before(rec.Created.Add(opts.Window))) {
+				mu.Unlock()
+				return rec.Output, nil
+			}
+			cl := &call{done: make(chan struct{})}
+			calls[key] = cl
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(calls, key)
+				mu.Unlock()
+				close(cl.done)
+			}()
+			cl.err = errPanicked
+			cl.out, cl.err = next(ctx, in)
+			if cl.err == nil {
+				rec := IdempotencyRecord{Key: key, Output: cl.out, Created: clock.Now()}
+				if err := store.Put(rec); err != nil {
+					cl.err = fmt.Errorf("idempotency store: %w", err)
+				}
+			}
+			return cl.out, cl.err
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// sideEffect returns a handler that counts its calls and returns the call number with input
+func sideEffect(calls *atomic.Int32) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		n := calls.Add(1)
+		return in + string(rune('0'+n)), nil
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var calls atomic.Int32
+	r := New()
+	r.Handle("/pay", sideEffect(&calls))
+	r.Use("/pay", Idempotency(IdempotencyOptions{
+		Key:    ByKey(clientID),
+		Window: time.Minute,
+		Clock:  clock,
+	}))
+	tests := []test{
+		{"k1:", "k1:1"},
+		{"k1:", "k1:1"},
+		{"k2:", "k2:2"},
+		// requests without a key are not deduplicated
+		{"", "3"},
+		{"", "4"},
+	}
+	runRouterTests(t, r, "replayed results", "/pay", tests)
+
+	clock.Advance(time.Minute)
+	runRouterTests(t, r, "window expired", "/pay", []test{
+		{"k1:", "k1:5"},
+		{"k1:", "k1:5"},
+	})
+}
+
+func TestIdempotencyConcurrent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := New()
+	r.Handle("/pay", func(ctx context.Context, in string) (string, error) {
+		<-release
+		return sideEffect(&calls)(ctx, in)
+	})
+	r.Use("/pay", Idempotency(IdempotencyOptions{Key: ByKey(clientID)}))
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = r.Match(routing.Request{Path: "/pay", Data: "k:"})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single handler call, got: %d", n)
+	}
+	for i, res := range results {
+		if res != "k:1" {
+			t.Errorf("request %d, expected: k:1, got: %s", i, res)
+		}
+	}
+}
+
+func TestIdempotencyCancelled(t *testing.T) {
+	var calls atomic.Int32
+	r := New()
+	r.Handle("/pay", func(ctx context.Context, in string) (string, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return in, nil
+	})
+	r.Use("/pay", Idempotency(IdempotencyOptions{Key: ByKey(clientID)}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := r.MatchContext(ctx, routing.Request{Path: "/pay", Data: "k:"})
+		first <- err
+	}()
+	waitFor(t, "the first request to run", func() bool { return calls.Load() == 1 })
+	duplicate := make(chan string)
+	go func() {
+		out, err := r.Match(routing.Request{Path: "/pay", Data: "k:"})
+		if err != nil {
+			t.Errorf("expected the duplicate to succeed, got: %v", err)
+		}
+		duplicate <- out
+	}()
+	// let the duplicate wait for the first request
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled request to fail, got: %v", err)
+	}
+	if out := <-duplicate; out != "k:" {
+		t.Errorf("expected the duplicate to run the handler itself, got: %s", out)
+	}
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Idempotency without a key function to panic")
+		}
+	}()
+	Idempotency(IdempotencyOptions{})
+}
+
+func TestIdempotencyFileStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	var calls atomic.Int32
+	newRouter := func(store IdempotencyStore) *Router {
+		r := New()
+		r.Handle("/pay", sideEffect(&calls))
+		r.Use("/pay", Idempotency(IdempotencyOptions{Key: ByKey(clientID), Store: store}))
+		return r
+	}
+
+	store, err := OpenFileStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runRouterTests(t, newRouter(store), "first run", "/pay", []test{
+		{"k1:", "k1:1"},
+		{"k2:", "k2:2"},
+	})
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// results survive reopening the store
+	store, err = OpenFileStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	runRouterTests(t, newRouter(store), "after reopening", "/pay", []test{
+		{"k1:", "k1:1"},
+		{"k2:", "k2:2"},
+		{"k3:", "k3:3"},
+	})
+}
@@ -0,0 +1,71 @@
+package mux
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// LogOptions configures the Logging middleware. The zero value logs every request
+// together with its data.
+type LogOptions struct {
+	// SampleRate is the fraction of successful requests that are logged, from 0 to 1.
+	// Zero means every request is logged. Failed requests are always logged.
+	SampleRate float64
+	// Redact, if set, is applied to request data before it is logged.
+	// Use Redacted to leave the data out entirely.
+	Redact func(data string) string
+	// Rand returns a number in [0, 1) and is used for sampling, rand.Float64 by default.
+	Rand func() float64
+}
+
+// Redacted replaces any data with a placeholder, use it as LogOptions.Redact.
+func Redacted(string) string {
+	return "[redacted]"
+}
+
+// Logging returns a middleware that writes a record to logger for every request that
+// passes through it. Records contain the request path, the matched route, data, input and
+// output size, duration, and the error and the request ID, if any. Successful requests are
+// logged at info level, failed ones at error level.
+func Logging(logger *slog.Logger, opts LogOptions) Middleware {
+	random := opts.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			start := time.Now()
+			out, err := next(ctx, in)
+			d := time.Since(start)
+			if err == nil && opts.SampleRate > 0 && random() >= opts.SampleRate {
+				return out, err
+			}
+
+			req, _ := RequestFrom(ctx)
+			data := in
+			if opts.Redact != nil {
+				data = opts.Redact(data)
+			}
+			attrs := []slog.Attr{
+				slog.String("path", req.Path),
+				slog.String("route", RouteFrom(ctx)),
+				slog.String("data", data),
+				slog.Int("input_size", len(in)),
+				slog.Int("output_size", len(out)),
+				slog.Duration("duration", d),
+			}
+			if id := RequestIDFrom(ctx); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			level := slog.LevelInfo
+			if err != nil {
+				level = slog.LevelError
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			logger.LogAttrs(ctx, level, "request", attrs...)
+			return out, err
+		}
+	}
+}
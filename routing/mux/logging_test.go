@@ -0,0 +1,94 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func decodeLog(t *testing.T, b *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	dec := json.NewDecoder(b)
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestLogging(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&b, nil))
+	r := New(WithMiddleware(Logging(logger, LogOptions{Redact: Redacted})))
+	r.RegisterHandler("/double", func(s string) string { return s + s })
+	r.Handle("/fail", func(ctx context.Context, in string) (string, error) {
+		return "", errors.New("boom")
+	})
+	r.Match(routing.Request{Path: "/double", Data: "secret"})
+	r.Match(routing.Request{Path: "/fail", Data: "x"})
+
+	records := decodeLog(t, &b)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got: %d", len(records))
+	}
+	expected := []map[string]any{
+		{"level": "INFO", "path": "/double", "route": "/double", "data": "[redacted]", "input_size": 6.0, "output_size": 12.0},
+		{"level": "ERROR", "path": "/fail", "route": "/fail", "error": "boom"},
+	}
+	for i, exp := range expected {
+		for k, v := range exp {
+			if records[i][k] != v {
+				t.Errorf("record %d, attribute %s, expected: %v, got: %v", i, k, v, records[i][k])
+			}
+		}
+		if _, ok := records[i]["duration"]; !ok {
+			t.Errorf("record %d has no duration", i)
+		}
+	}
+	if strings.Contains(b.String(), "secret") {
+		t.Errorf("data was not redacted")
+	}
+}
+
+func TestLoggingSampling(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&b, nil))
+	// a fixed sequence of "random" numbers, only the ones below the rate are logged
+	rolls := []float64{0.1, 0.9, 0.4, 0.6}
+	opts := LogOptions{
+		SampleRate: 0.5,
+		Rand: func() float64 {
+			v := rolls[0]
+			rolls = rolls[1:]
+			return v
+		},
+	}
+	r := New()
+	r.RegisterHandler("/id", identity)
+	r.Handle("/fail", func(ctx context.Context, in string) (string, error) {
+		return "", errors.New("boom")
+	})
+	r.Use("/id", Logging(logger, opts))
+	r.Use("/fail", Logging(logger, opts))
+	for _, data := range []string{"a", "b", "c", "d"} {
+		r.Match(routing.Request{Path: "/id", Data: data})
+	}
+	r.Match(routing.Request{Path: "/fail", Data: "e"})
+
+	var logged []string
+	for _, rec := range decodeLog(t, &b) {
+		logged = append(logged, rec["data"].(string))
+	}
+	if got := strings.Join(logged, ""); got != "ace" {
+		t.Errorf("expected requests a, c and the failed e to be logged, got: %s", got)
+	}
+}
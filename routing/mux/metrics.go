@@ -0,0 +1,136 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are upper bounds of latency histogram buckets, in seconds.
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// Metrics collects per-route request counts, error counts and latency histograms, as well
+// as the number of requests that matched no route. Collect them either with Metrics.Hook
+// installed on a router, or with Metrics.Middleware on selected routes, but not both:
+// requests would be counted twice. Only the hook sees requests that matched nothing.
+type Metrics struct {
+	buckets []float64
+
+	mu       sync.Mutex
+	routes   map[string]*routeMetrics
+	notFound uint64
+}
+
+type routeMetrics struct {
+	requests, errors uint64
+	// counts[i] is the number of observations that fell into buckets[i], not cumulative
+	counts []uint64
+	sum    float64
+}
+
+// NewMetrics creates a Metrics with the given histogram buckets, DefaultBuckets if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{buckets: buckets, routes: make(map[string]*routeMetrics)}
+}
+
+// Hook records a finished Match, install it with WithHook(m.Hook).
+func (m *Metrics) Hook(info MatchInfo) {
+	if errors.Is(info.Err, ErrNotFound) && info.Route == "" {
+		m.mu.Lock()
+		m.notFound++
+		m.mu.Unlock()
+		return
+	}
+	m.observe(info.Route, info.Duration, info.Err)
+}
+
+// Middleware returns a middleware that records every request that passes through it
+// under the route that matched the request.
+func (m *Metrics) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			start := time.Now()
+			out, err := next(ctx, in)
+			m.observe(RouteFrom(ctx), time.Since(start), err)
+			return out, err
+		}
+	}
+}
+
+func (m *Metrics) observe(route string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rm, ok := m.routes[route]
+	if !ok {
+		rm = &routeMetrics{counts: make([]uint64, len(m.buckets)+1)}
+		m.routes[route] = rm
+	}
+	rm.requests++
+	if err != nil {
+		rm.errors++
+	}
+	seconds := d.Seconds()
+	rm.sum += seconds
+	// the last count is the +Inf bucket
+	rm.counts[sort.SearchFloat64s(m.buckets, seconds)]++
+}
+
+// WritePrometheus writes all metrics to w in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	routes := sortedKeys(m.routes)
+
+	b.WriteString("# HELP routing_requests_total Requests handled, by route.\n")
+	b.WriteString("# TYPE routing_requests_total counter\n")
+	for _, route := range routes {
+		fmt.Fprintf(&b, "routing_requests_total{route=%s} %d\n", quoteLabel(route), m.routes[route].requests)
+	}
+
+	b.WriteString("# HELP routing_errors_total Requests that returned an error, by route.\n")
+	b.WriteString("# TYPE routing_errors_total counter\n")
+	for _, route := range routes {
+		fmt.Fprintf(&b, "routing_errors_total{route=%s} %d\n", quoteLabel(route), m.routes[route].errors)
+	}
+
+	b.WriteString("# HELP routing_not_found_total Requests that matched no route.\n")
+	b.WriteString("# TYPE routing_not_found_total counter\n")
+	fmt.Fprintf(&b, "routing_not_found_total %d\n", m.notFound)
+
+	b.WriteString("# HELP routing_request_duration_seconds Request latency, by route.\n")
+	b.WriteString("# TYPE routing_request_duration_seconds histogram\n")
+	for _, route := range routes {
+		rm := m.routes[route]
+		label := quoteLabel(route)
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += rm.counts[i]
+			fmt.Fprintf(&b, "routing_request_duration_seconds_bucket{route=%s,le=\"%s\"} %d\n",
+				label, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "routing_request_duration_seconds_bucket{route=%s,le=\"+Inf\"} %d\n", label, rm.requests)
+		fmt.Fprintf(&b, "routing_request_duration_seconds_sum{route=%s} %s\n", label, strconv.FormatFloat(rm.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "routing_request_duration_seconds_count{route=%s} %d\n", label, rm.requests)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,117 @@
+package mux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// parsePrometheus parses text exposition format into a map from series, i.e. name with labels,
+// to value. It checks that every series belongs to a metric declared with TYPE.
+func parsePrometheus(t *testing.T, text string) map[string]float64 {
+	series := make(map[string]float64)
+	types := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			types[fields[2]] = fields[3]
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("malformed line: %q", line)
+		}
+		name, value := line[:i], line[i+1:]
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("malformed value in line: %q", line)
+		}
+		metric, _, _ := strings.Cut(name, "{")
+		base := metric
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if b, ok := strings.CutSuffix(metric, suffix); ok && types[b] == "histogram" {
+				base = b
+			}
+		}
+		if _, ok := types[base]; !ok {
+			t.Errorf("series %s has no TYPE", name)
+		}
+		series[name] = v
+	}
+	return series
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(1, 10)
+	r := New(WithHook(m.Hook))
+	r.RegisterHandler("/ok", identity)
+	r.Handle("/fail", func(ctx context.Context, in string) (string, error) {
+		return "", errors.New("fail")
+	})
+	r.RegisterHandler(`/"quoted"`, identity)
+	for _, path := range []string{"/ok", "/ok", "/fail", "/nope", `/"quoted"`} {
+		r.Match(routing.Request{Path: path})
+	}
+
+	var b strings.Builder
+	if err := m.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	series := parsePrometheus(t, b.String())
+	expected := map[string]float64{
+		`routing_requests_total{route="/ok"}`:                            2,
+		`routing_requests_total{route="/fail"}`:                          1,
+		`routing_requests_total{route="/\"quoted\""}`:                    1,
+		`routing_errors_total{route="/ok"}`:                              0,
+		`routing_errors_total{route="/fail"}`:                            1,
+		`routing_not_found_total`:                                        1,
+		`routing_request_duration_seconds_bucket{route="/ok",le="1"}`:    2,
+		`routing_request_duration_seconds_bucket{route="/ok",le="10"}`:   2,
+		`routing_request_duration_seconds_bucket{route="/ok",le="+Inf"}`: 2,
+		`routing_request_duration_seconds_count{route="/fail"}`:          1,
+	}
+	for name, value := range expected {
+		got, ok := series[name]
+		if !ok {
+			t.Errorf("series %s is missing in output:\n%s", name, b.String())
+			continue
+		}
+		if got != value {
+			t.Errorf("series %s, expected: %v, got: %v", name, value, got)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	m := NewMetrics()
+	r := New()
+	r.RegisterHandler("/ok", identity)
+	r.Use("/ok", m.Middleware())
+	r.Match(routing.Request{Path: "/ok"})
+	r.Match(routing.Request{Path: "/nope"})
+
+	var b strings.Builder
+	if err := m.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	series := parsePrometheus(t, b.String())
+	if v := series[`routing_requests_total{route="/ok"}`]; v != 1 {
+		t.Errorf("expected 1 request on /ok, got: %v", v)
+	}
+	if v := series[`routing_request_duration_seconds_bucket{route="/ok",le="+Inf"}`]; v != 1 {
+		t.Errorf("expected 1 observation on /ok, got: %v", v)
+	}
+	if v := series["routing_not_found_total"]; v != 0 {
+		t.Errorf("middleware cannot see unmatched requests, got: %v", v)
+	}
+}
@@ -0,0 +1,312 @@
+// Package mux is a ready-made implementation of the routing system described in package routing.
+// It is meant for experimenting with routing tables and middleware chains without solving the
+// exercises first, and for the tools in routing/cmd.
+//
+// Handlers in this package are richer than routing.Handler: they receive a context and may fail.
+// Plain routing handlers and middlewares can be used everywhere through Wrap and WrapMiddleware.
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// Handler is a context-aware counterpart of routing.Handler that can return an error.
+type Handler func(ctx context.Context, in string) (string, error)
+
+// Middleware takes a handler and returns another handler, same as routing.Middleware.
+type Middleware func(Handler) Handler
+
+// Wrap turns a routing.Handler into a Handler that never fails.
+func Wrap(h routing.Handler) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		return h(in), nil
+	}
+}
+
+// WrapMiddleware turns a routing.Middleware into a Middleware.
+// The wrapped middleware sees the context of the request it is running for, but errors
+// returned by the next handler cannot be passed through a routing.Handler, so the first
+// error short-circuits the rest of the chain.
+func WrapMiddleware(mw routing.Middleware) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			var err error
+			h := mw(func(s string) string {
+				if err != nil {
+					return ""
+				}
+				var out string
+				out, err = next(ctx, s)
+				return out
+			})
+			out := h(in)
+			if err != nil {
+				return "", err
+			}
+			return out, nil
+		}
+	}
+}
+
+// ErrNotFound is returned by Match when no handler is registered for the request path.
+var ErrNotFound = errors.New("no handler registered")
+
+type route struct {
+	handler Handler
+	rules   []rule
+	mws     []Middleware
+	timeout time.Duration
+	// chain is handler with all the middlewares applied, nil until the handler is registered
+	chain Handler
+	// compiled are rules with their chains, the slice is replaced on every compile
+	compiled []compiledRule
+}
+
+// Router matches request paths exactly and runs the handler registered for the path
+// with all its middlewares. Router is safe for concurrent use.
+type Router struct {
+	mu     sync.RWMutex
+	routes map[string]*route
+	global []Middleware
+	hooks  []func(MatchInfo)
+	tracer *Tracer
+	// recover panics in handlers, see WithRecovery
+	recover bool
+	timeout time.Duration
+	// requestID generates IDs of requests, see WithRequestID
+	requestID func() string
+}
+
+// Option configures a Router.
+type Option func(*Router)
+
+// MatchInfo describes a finished Match, it is passed to hooks installed with WithHook.
+type MatchInfo struct {
+	Request routing.Request
+	// RequestID is the ID of the request, empty if it has none, see RequestIDFrom
+	RequestID string
+	// Route is the route that matched the request, empty if none did
+	Route    string
+	Output   string
+	Err      error
+	Duration time.Duration
+}
+
+// WithHook installs a function that is called after every Match, including the ones
+// that did not match any route. Hooks are called synchronously, in the order they were installed.
+func WithHook(hook func(MatchInfo)) Option {
+	return func(r *Router) {
+		r.hooks = append(r.hooks, hook)
+	}
+}
+
+// WithMiddleware adds middlewares to every route of the router. They run before
+// the middlewares added to a route with Use or UseMiddleware.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(r *Router) {
+		r.global = append(r.global, mws...)
+	}
+}
+
+// New creates an empty Router.
+func New(opts ...Option) *Router {
+	r := &Router{routes: make(map[string]*route)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+var _ routing.Router = (*Router)(nil)
+
+// RegisterHandler implements routing.Router.
+func (r *Router) RegisterHandler(path string, h routing.Handler) {
+	r.Handle(path, Wrap(h))
+}
+
+// UseMiddleware implements routing.Router.
+func (r *Router) UseMiddleware(path string, mw routing.Middleware) {
+	r.Use(path, WrapMiddleware(mw))
+}
+
+// Handle registers h for the given path, replacing the previous handler if there was one.
+func (r *Router) Handle(path string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt := r.route(path)
+	rt.handler = h
+	r.compile(rt)
+}
+
+// Use adds middlewares to the given path. Middlewares run in the order they were added,
+// and it does not matter whether they are added before or after the handler.
+func (r *Router) Use(path string, mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt := r.route(path)
+	rt.mws = append(rt.mws, mws...)
+	r.compile(rt)
+}
+
+// compile builds the chain of the route, global middlewares run before the route ones.
+// With a tracer installed, every layer of the chain is traced. The timeout, if any,
+// bounds the whole chain. r.mu must be held for writing.
+func (r *Router) compile(rt *route) {
+	rt.chain = nil
+	if rt.handler != nil {
+		rt.chain = r.chain(rt, rt.handler)
+	}
+	rt.compiled = make([]compiledRule, len(rt.rules))
+	for i, ru := range rt.rules {
+		rt.compiled[i] = compiledRule{rule: ru, chain: r.chain(rt, ru.handler)}
+	}
+}
+
+// chain applies middlewares of the route to h. r.mu must be held.
+func (r *Router) chain(rt *route, h Handler) Handler {
+	mws := append(append([]Middleware(nil), r.global...), rt.mws...)
+	h = r.tracer.layer("handler", h)
+	// the middleware registered first should run first, so it has to be applied last
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = r.tracer.layer(fmt.Sprintf("middleware %d %s", i, funcName(mws[i])), mws[i](h))
+	}
+	timeout := rt.timeout
+	if timeout == 0 {
+		timeout = r.timeout
+	}
+	if timeout > 0 {
+		h = Timeout(timeout)(h)
+	}
+	return h
+}
+
+// route returns the route for path, creating it if needed. r.mu must be held for writing.
+func (r *Router) route(path string) *route {
+	rt, ok := r.routes[path]
+	if !ok {
+		rt = &route{}
+		r.routes[path] = rt
+	}
+	return rt
+}
+
+// Match implements routing.Router.
+func (r *Router) Match(req routing.Request) (string, error) {
+	return r.MatchContext(context.Background(), req)
+}
+
+// MatchContext runs the handler registered for req.Path on req.Data. The context is
+// passed down the middleware chain and carries the request, see RequestFrom, as well as
+// its ID if the router was created WithRequestID.
+func (r *Router) MatchContext(ctx context.Context, req routing.Request) (string, error) {
+	if r.requestID != nil {
+		ctx = withRequestID(ctx, r.requestID)
+	}
+	if len(r.hooks) == 0 {
+		_, out, err := r.match(ctx, req)
+		return out, err
+	}
+	start := time.Now()
+	route, out, err := r.match(ctx, req)
+	info := MatchInfo{
+		Request:   req,
+		RequestID: RequestIDFrom(ctx),
+		Route:     route,
+		Output:    out,
+		Err:       err,
+		Duration:  time.Since(start),
+	}
+	for _, hook := range r.hooks {
+		hook(info)
+	}
+	return out, err
+}
+
+// match runs the request through the matching route and returns the route along with the result
+func (r *Router) match(ctx context.Context, req routing.Request) (string, string, error) {
+	r.mu.RLock()
+	var h Handler
+	var rules []compiledRule
+	if rt, ok := r.routes[req.Path]; ok {
+		h, rules = rt.chain, rt.compiled
+	}
+	r.mu.RUnlock()
+	route := req.Path
+	ru, err := r.matchRule(req, rules)
+	if err != nil {
+		return route, "", err
+	}
+	if ru != nil {
+		h, route = ru.chain, req.Path+" "+ru.name
+	}
+	if h == nil {
+		return "", "", fmt.Errorf("%w for path %q", ErrNotFound, req.Path)
+	}
+	ctx, end := r.tracer.start(withRequest(ctx, req, route), "match "+route)
+	out, err := r.call(ctx, h, req.Data)
+	end(err)
+	return route, out, err
+}
+
+// matchRule returns the first of rules that matches req, nil if none does. Matchers are
+// user code, so their panics are recovered the same way as in handlers.
+func (r *Router) matchRule(req routing.Request, rules []compiledRule) (ru *compiledRule, err error) {
+	if r.recover {
+		defer recoverTo(&err)
+	}
+	for i := range rules {
+		if rules[i].match(req) {
+			return &rules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *Router) call(ctx context.Context, h Handler, in string) (out string, err error) {
+	if r.recover {
+		defer recoverTo(&err)
+	}
+	return h(ctx, in)
+}
+
+// Paths returns all paths that have a handler or a content rule registered, in no particular order.
+func (r *Router) Paths() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	paths := make([]string, 0, len(r.routes))
+	for path, rt := range r.routes {
+		if rt.handler != nil || len(rt.rules) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+type requestKey struct{}
+
+type routeKey struct{}
+
+func withRequest(ctx context.Context, req routing.Request, route string) context.Context {
+	ctx = context.WithValue(ctx, requestKey{}, req)
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RequestFrom returns the request that is being matched. Data of the returned request is
+// the original one, before any middleware had a chance to change it.
+func RequestFrom(ctx context.Context) (routing.Request, bool) {
+	req, ok := ctx.Value(requestKey{}).(routing.Request)
+	return req, ok
+}
+
+// RouteFrom returns the route that matched the request: the path, followed by a space and
+// the rule name if the request was matched by a content rule, see HandleWhen.
+func RouteFrom(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
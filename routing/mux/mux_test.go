@@ -0,0 +1,97 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+type test struct {
+	input, expected string
+}
+
+func runRouterTests(t *testing.T, r *Router, name, path string, tests []test) {
+	t.Run(name, func(t *testing.T) {
+		for _, test := range tests {
+			res, err := r.Match(routing.Request{Path: path, Data: test.input})
+			if err != nil {
+				t.Errorf("input: %s, expected: %s, got error: %s", test.input, test.expected, err)
+			}
+			if res != test.expected {
+				t.Errorf("input: %s, expected: %s, got: %s", test.input, test.expected, res)
+			}
+		}
+	})
+}
+
+func identity(s string) string {
+	return s
+}
+
+func TestRouter(t *testing.T) {
+	r := New()
+	rev, _ := LookupMiddleware("reverse")
+	capt, _ := LookupMiddleware("capitalize")
+
+	r.RegisterHandler("/identity", identity)
+	runRouterTests(t, r, "identity handler", "/identity", []test{
+		{"a", "a"},
+		{"", ""},
+	})
+
+	r.UseMiddleware("/revcap", rev)
+	r.UseMiddleware("/revcap", capt)
+	r.RegisterHandler("/revcap", identity)
+	runRouterTests(t, r, "reverse, then capitalize", "/revcap", []test{
+		{"", ""},
+		{"abcd", "Dcba"},
+	})
+
+	r.RegisterHandler("/caprev", identity)
+	r.UseMiddleware("/caprev", capt)
+	r.UseMiddleware("/caprev", rev)
+	runRouterTests(t, r, "capitalize, then reverse", "/caprev", []test{
+		{"", ""},
+		{"abcd", "dcbA"},
+	})
+
+	res, err := r.Match(routing.Request{Path: "/nope"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on unregistered path, got result: %s, error: %v", res, err)
+	}
+
+	r.UseMiddleware("/only-mw", rev)
+	if _, err := r.Match(routing.Request{Path: "/only-mw"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on path without handler, got: %v", err)
+	}
+}
+
+func TestWrapMiddlewareError(t *testing.T) {
+	boom := errors.New("boom")
+	r := New()
+	r.Handle("/fail", func(ctx context.Context, in string) (string, error) {
+		return "", boom
+	})
+	r.UseMiddleware("/fail", func(h routing.Handler) routing.Handler {
+		return func(in string) string { return h(in) + "!" }
+	})
+	if res, err := r.Match(routing.Request{Path: "/fail"}); !errors.Is(err, boom) {
+		t.Errorf("expected handler error to pass through middleware, got result: %s, error: %v", res, err)
+	}
+}
+
+func TestRequestFrom(t *testing.T) {
+	r := New()
+	r.Handle("/req", func(ctx context.Context, in string) (string, error) {
+		req, _ := RequestFrom(ctx)
+		return req.Path + ":" + req.Data, nil
+	})
+	r.UseMiddleware("/req", func(h routing.Handler) routing.Handler {
+		return func(in string) string { return h(in + in) }
+	})
+	runRouterTests(t, r, "request in context", "/req", []test{
+		{"a", "/req:a"},
+	})
+}
@@ -0,0 +1,119 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// ErrRateLimited matches every RateLimitedError with errors.Is.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitedError is returned when a request is rejected by the RateLimit middleware.
+type RateLimitedError struct {
+	// Key is the key the request was limited by
+	Key string
+	// RetryAfter is how long it takes until the next request with this key is allowed
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited %q, retry after %v", e.Key, e.RetryAfter)
+}
+
+func (e *RateLimitedError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// KeyFunc returns a key a request is grouped by. The request is the original one,
+// as returned by RequestFrom.
+type KeyFunc func(ctx context.Context, req routing.Request) string
+
+// ByRoute groups requests by the route they matched.
+func ByRoute(ctx context.Context, req routing.Request) string {
+	return RouteFrom(ctx)
+}
+
+// ByKey groups requests by a key extracted from the request, for example a client ID in Data.
+func ByKey(extract func(routing.Request) string) KeyFunc {
+	return func(ctx context.Context, req routing.Request) string {
+		return extract(req)
+	}
+}
+
+// ByRouteAndKey groups requests both by route and by a key extracted from the request.
+func ByRouteAndKey(extract func(routing.Request) string) KeyFunc {
+	return func(ctx context.Context, req routing.Request) string {
+		return RouteFrom(ctx) + " " + extract(req)
+	}
+}
+
+// RateLimitOptions configures the RateLimit middleware.
+type RateLimitOptions struct {
+	// Rate is how many requests per second are allowed for every key in the long run
+	Rate float64
+	// Burst is how many requests can be made at once, at least 1
+	Burst int
+	// Key groups requests, every group has its own limit. ByRoute if not set.
+	Key KeyFunc
+	// Clock is SystemClock if not set
+	Clock Clock
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimit returns a token bucket rate limiting middleware. Every key has a bucket of
+// Burst tokens that refills at Rate tokens per second, and every request takes a token.
+// Requests that find the bucket empty fail with RateLimitedError.
+// Buckets are kept for every key ever seen, so keys should come from a bounded set.
+func RateLimit(opts RateLimitOptions) Middleware {
+	key := opts.Key
+	if key == nil {
+		key = ByRoute
+	}
+	burst := float64(max(opts.Burst, 1))
+	clock := clockOrSystem(opts.Clock)
+	var mu sync.Mutex
+	buckets := make(map[string]*bucket)
+
+	// take takes a token from the bucket of k, or returns how long to wait for one
+	take := func(k string) (time.Duration, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := clock.Now()
+		b, ok := buckets[k]
+		if !ok {
+			b = &bucket{tokens: burst, last: now}
+			buckets[k] = b
+		}
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*opts.Rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			return 0, true
+		}
+		if opts.Rate <= 0 {
+			return time.Duration(math.MaxInt64), false
+		}
+		return time.Duration((1 - b.tokens) / opts.Rate * float64(time.Second)), false
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			req, _ := RequestFrom(ctx)
+			k := key(ctx, req)
+			if wait, ok := take(k); !ok {
+				return "", &RateLimitedError{Key: k, RetryAfter: wait}
+			}
+			return next(ctx, in)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// clientID extracts a client ID from requests with data like "client:payload"
+func clientID(req routing.Request) string {
+	id, _, _ := strings.Cut(req.Data, ":")
+	return id
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	tests := []struct {
+		name string
+		key  KeyFunc
+		// requests are made in order, expected tells which ones are allowed
+		requests []routing.Request
+		expected []bool
+	}{
+		{
+			name: "by route",
+			key:  ByRoute,
+			requests: []routing.Request{
+				{Path: "/a", Data: "1:x"}, {Path: "/a", Data: "2:x"}, {Path: "/a", Data: "3:x"},
+				{Path: "/b", Data: "1:x"},
+			},
+			expected: []bool{true, true, false, true},
+		},
+		{
+			name: "by key",
+			key:  ByKey(clientID),
+			requests: []routing.Request{
+				{Path: "/a", Data: "1:x"}, {Path: "/b", Data: "1:x"}, {Path: "/a", Data: "1:x"},
+				{Path: "/a", Data: "2:x"},
+			},
+			expected: []bool{true, true, false, true},
+		},
+		{
+			name: "by route and key",
+			key:  ByRouteAndKey(clientID),
+			requests: []routing.Request{
+				{Path: "/a", Data: "1:x"}, {Path: "/a", Data: "1:x"}, {Path: "/a", Data: "1:x"},
+				{Path: "/b", Data: "1:x"}, {Path: "/a", Data: "2:x"},
+			},
+			expected: []bool{true, true, false, true, true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := New(WithMiddleware(RateLimit(RateLimitOptions{Rate: 1, Burst: 2, Key: test.key, Clock: clock})))
+			r.RegisterHandler("/a", identity)
+			r.RegisterHandler("/b", identity)
+			for i, req := range test.requests {
+				_, err := r.Match(req)
+				if allowed := err == nil; allowed != test.expected[i] {
+					t.Errorf("request %d %+v, expected allowed: %v, got error: %v", i, req, test.expected[i], err)
+				}
+				if err != nil && !errors.Is(err, ErrRateLimited) {
+					t.Errorf("request %d, expected rate limited error, got: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	r := New()
+	r.RegisterHandler("/a", identity)
+	r.Use("/a", RateLimit(RateLimitOptions{Rate: 2, Burst: 1, Clock: clock}))
+	req := routing.Request{Path: "/a"}
+
+	if _, err := r.Match(req); err != nil {
+		t.Fatalf("expected first request to pass, got: %v", err)
+	}
+	_, err := r.Match(req)
+	var rle *RateLimitedError
+	if !errors.As(err, &rle) {
+		t.Fatalf("expected RateLimitedError, got: %v", err)
+	}
+	if rle.Key != "/a" || rle.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected key /a and retry after 500ms, got: %+v", rle)
+	}
+	clock.Advance(499 * time.Millisecond)
+	if _, err := r.Match(req); err == nil {
+		t.Errorf("expected request before refill to be limited")
+	}
+	clock.Advance(time.Millisecond)
+	if _, err := r.Match(req); err != nil {
+		t.Errorf("expected request after refill to pass, got: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package mux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// RecordingVersion is the version of the recording format written by Recorder.
+//
+// A recording is a file of JSON lines. The first line is a header, {"version": N},
+// and every other line is a Recorded request.
+const RecordingVersion = 1
+
+// ErrRecordingVersion is returned by ReadRecording for recordings in an unknown format.
+var ErrRecordingVersion = errors.New("unsupported recording version")
+
+type recordingHeader struct {
+	Version int `json:"version"`
+}
+
+// Recorded is a request together with the result it got when it was recorded.
+type Recorded struct {
+	Path   string `json:"path"`
+	Data   string `json:"data"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Request returns the recorded request.
+func (rec Recorded) Request() routing.Request {
+	return routing.Request{Path: rec.Path, Data: rec.Data}
+}
+
+// Recorder writes requests and their results to a recording, to be replayed later
+// with Router.Replay. Recorder is safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+	// err is the first error writing the recording
+	err error
+}
+
+// NewRecorder writes the recording header to w and returns a recorder writing to it.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(recordingHeader{Version: RecordingVersion}); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, enc: enc}, nil
+}
+
+// CreateRecorder creates the named file, truncating it if it exists, and returns a
+// recorder writing to it. Close the recorder to close the file.
+func CreateRecorder(name string) (*Recorder, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := NewRecorder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Middleware returns a middleware that records every request it sees along with the
+// result of the rest of the chain. The request data is recorded as it came to Match, so
+// install the middleware first with WithMiddleware to record what the route returned.
+// Requests that match no route never reach a middleware, so they are not recorded.
+// Errors writing the recording do not fail requests, they are returned by Err and Close.
+func (rc *Recorder) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			out, err := next(ctx, in)
+			req, _ := RequestFrom(ctx)
+			rc.record(Recorded{Path: req.Path, Data: req.Data, Output: out, Error: errorString(err)})
+			return out, err
+		}
+	}
+}
+
+func (rc *Recorder) record(rec Recorded) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.err == nil {
+		rc.err = rc.enc.Encode(rec)
+	}
+}
+
+// Err returns the first error writing the recording.
+func (rc *Recorder) Err() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.err
+}
+
+// Close closes the underlying writer if it is an io.Closer, and returns the first error
+// writing the recording, if any.
+func (rc *Recorder) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	err := rc.err
+	if c, ok := rc.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ReadRecording reads a recording written by Recorder.
+func ReadRecording(r io.Reader) ([]Recorded, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("empty recording")
+	}
+	var header recordingHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("recording header: %w", err)
+	}
+	if header.Version != RecordingVersion {
+		return nil, fmt.Errorf("%w %d", ErrRecordingVersion, header.Version)
+	}
+	var recs []Recorded
+	for line := 2; sc.Scan(); line++ {
+		var rec Recorded
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
+
+// Difference is a recorded request that got a different result when replayed.
+type Difference struct {
+	// Index of the request in the recording
+	Index    int
+	Recorded Recorded
+	// Output and Error are the result of the replay
+	Output string
+	Error  string
+}
+
+// Replay matches the recorded requests one by one, in order, and returns the ones whose
+// output or error message differ from the recorded ones.
+func (r *Router) Replay(ctx context.Context, recs []Recorded) []Difference {
+	var diffs []Difference
+	for i, rec := range recs {
+		out, err := r.MatchContext(ctx, rec.Request())
+		if msg := errorString(err); out != rec.Output || msg != rec.Error {
+			diffs = append(diffs, Difference{Index: i, Recorded: rec, Output: out, Error: msg})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,133 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "recording.jsonl")
+	rec, err := CreateRecorder(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(WithMiddleware(rec.Middleware()))
+	r.RegisterHandler("/upper", strings.ToUpper)
+	r.Use("/upper", builtinMiddleware(t, "bangify"))
+	r.RegisterHandler("/rev", func(s string) string { return s })
+	r.Handle("/fail", failing(errTemporary))
+	reqs := []routing.Request{
+		{Path: "/upper", Data: "a"},
+		{Path: "/rev", Data: "ab"},
+		{Path: "/fail", Data: "c"},
+		{Path: "/nope", Data: "d"},
+	}
+	for _, req := range reqs {
+		r.Match(req)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recs, err := ReadRecording(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Recorded{
+		{Path: "/upper", Data: "a", Output: "A!"},
+		{Path: "/rev", Data: "ab", Output: "ab"},
+		{Path: "/fail", Data: "c", Error: errTemporary.Error()},
+	}
+	if len(recs) != len(expected) {
+		t.Fatalf("expected %d recorded requests, got: %+v", len(expected), recs)
+	}
+	for i, exp := range expected {
+		if recs[i] != exp {
+			t.Errorf("request %d, expected: %+v, got: %+v", i, exp, recs[i])
+		}
+	}
+
+	// the refactored router fixes /rev and /fail, and keeps /upper
+	fixed := New()
+	fixed.RegisterHandler("/upper", func(s string) string { return strings.ToUpper(s) + "!" })
+	fixed.RegisterHandler("/rev", reverse)
+	fixed.RegisterHandler("/fail", identity)
+	diffs := fixed.Replay(t.Context(), recs)
+	expectedDiffs := []Difference{
+		{Index: 1, Recorded: expected[1], Output: "ba"},
+		{Index: 2, Recorded: expected[2], Output: "c"},
+	}
+	if len(diffs) != len(expectedDiffs) {
+		t.Fatalf("expected %d differences, got: %+v", len(expectedDiffs), diffs)
+	}
+	for i, exp := range expectedDiffs {
+		if diffs[i] != exp {
+			t.Errorf("difference %d, expected: %+v, got: %+v", i, exp, diffs[i])
+		}
+	}
+}
+
+func TestReadRecordingErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"empty", "", nil},
+		{"newer version", "{\"version\": 2}\n", ErrRecordingVersion},
+		{"bad line", "{\"version\": 1}\nnot json\n", nil},
+	}
+	for _, test := range tests {
+		_, err := ReadRecording(strings.NewReader(test.input))
+		if err == nil || test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s, expected error: %v, got: %v", test.name, test.err, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := NewRecorder(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if recs, err := ReadRecording(&buf); err != nil || len(recs) != 0 {
+		t.Errorf("expected empty recording, got: %+v, error: %v", recs, err)
+	}
+}
+
+// shortWriter accepts n writes and fails the rest
+type shortWriter struct {
+	n int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errPermanent
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestRecorderWriteError(t *testing.T) {
+	rec, err := NewRecorder(&shortWriter{n: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(WithMiddleware(rec.Middleware()))
+	r.RegisterHandler("/ok", identity)
+	if out, err := r.Match(routing.Request{Path: "/ok", Data: "a"}); out != "a" || err != nil {
+		t.Errorf("expected recording errors not to fail requests, got: %q, error: %v", out, err)
+	}
+	if err := rec.Close(); !errors.Is(err, errPermanent) {
+		t.Errorf("expected Close to report the lost line, got: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package mux
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned instead of a panic in a handler or middleware, when the panic
+// was recovered by Recover or by a router created WithRecovery.
+type PanicError struct {
+	// Value is the value passed to panic
+	Value any
+	// Stack is the stack trace of the goroutine that panicked, taken when recovering
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverTo turns a panic into a PanicError stored in err. It must be deferred directly.
+func recoverTo(err *error) {
+	if v := recover(); v != nil {
+		*err = &PanicError{Value: v, Stack: debug.Stack()}
+	}
+}
+
+// Recover returns a middleware that converts panics in the rest of the chain to a PanicError.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (out string, err error) {
+			defer recoverTo(&err)
+			return next(ctx, in)
+		}
+	}
+}
+
+// WithRecovery makes the router convert panics in any middleware or handler to a PanicError
+// returned from Match.
+func WithRecovery() Option {
+	return func(r *Router) {
+		r.recover = true
+	}
+}
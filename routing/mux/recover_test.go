@@ -0,0 +1,52 @@
+package mux
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// notImplemented panics the same way exercise stubs of the routing guide do
+func notImplemented(in string) string {
+	panic("not implemented")
+}
+
+func TestRecover(t *testing.T) {
+	r := New()
+	r.RegisterHandler("/stub", notImplemented)
+	r.Use("/stub", Recover())
+	_, err := r.Match(routing.Request{Path: "/stub"})
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected PanicError, got: %v", err)
+	}
+	if pe.Value != "not implemented" {
+		t.Errorf("expected panic value: not implemented, got: %v", pe.Value)
+	}
+	if !strings.Contains(string(pe.Stack), "notImplemented") {
+		t.Errorf("expected stack trace to include the panicking function, got:\n%s", pe.Stack)
+	}
+}
+
+func TestWithRecovery(t *testing.T) {
+	r := New(WithRecovery())
+	r.RegisterHandler("/eof", func(string) string { panic(io.EOF) })
+	// a panicking middleware is recovered too
+	r.RegisterHandler("/mw", identity)
+	r.UseMiddleware("/mw", func(h routing.Handler) routing.Handler { return notImplemented })
+	r.RegisterHandler("/ok", identity)
+
+	if _, err := r.Match(routing.Request{Path: "/eof"}); !errors.Is(err, io.EOF) {
+		t.Errorf("expected panic value to be unwrapped, got: %v", err)
+	}
+	var pe *PanicError
+	if _, err := r.Match(routing.Request{Path: "/mw"}); !errors.As(err, &pe) {
+		t.Errorf("expected PanicError, got: %v", err)
+	}
+	runRouterTests(t, r, "router keeps working", "/ok", []test{
+		{"a", "a"},
+	})
+}
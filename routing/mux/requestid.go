@@ -0,0 +1,68 @@
+package mux
+
+import (
+	"context"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID supplies an ID for the request matched with this context, for example
+// one received from another service. Middlewares and handlers get it with RequestIDFrom.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the ID of the request, or an empty string if it has none.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	return newID(8)
+}
+
+// WithRequestID makes the router give every request that comes without an ID one from gen,
+// or from NewRequestID if gen is nil. The ID is set before the middleware chain runs, so
+// middlewares, handlers and hooks all see it, and MatchResult returns it to the caller.
+func WithRequestID(gen func() string) Option {
+	if gen == nil {
+		gen = NewRequestID
+	}
+	return func(r *Router) {
+		r.requestID = gen
+	}
+}
+
+// withRequestID returns ctx with an ID from gen, unless it already has one
+func withRequestID(ctx context.Context, gen func() string) context.Context {
+	if RequestIDFrom(ctx) != "" {
+		return ctx
+	}
+	return ContextWithRequestID(ctx, gen())
+}
+
+// Result is the outcome of matching a request.
+type Result struct {
+	Request routing.Request
+	// ID of the request, see RequestIDFrom
+	ID     string
+	Output string
+	Err    error
+}
+
+// MatchResult matches the request like MatchContext, and returns the result together with
+// the request ID. The ID is taken from ctx, and if there is none, a new one is generated
+// by the generator set WithRequestID, or by NewRequestID.
+func (r *Router) MatchResult(ctx context.Context, req routing.Request) Result {
+	gen := r.requestID
+	if gen == nil {
+		gen = NewRequestID
+	}
+	ctx = withRequestID(ctx, gen)
+	out, err := r.MatchContext(ctx, req)
+	return Result{Request: req, ID: RequestIDFrom(ctx), Output: out, Err: err}
+}
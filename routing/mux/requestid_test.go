@@ -0,0 +1,88 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// echoID is a handler that returns the request ID
+func echoID(ctx context.Context, in string) (string, error) {
+	return RequestIDFrom(ctx), nil
+}
+
+func TestRequestID(t *testing.T) {
+	n := 0
+	gen := func() string {
+		n++
+		return string(rune('a' + n - 1))
+	}
+	var hooked []string
+	ring := NewDeadLetterRing(1)
+	r := New(
+		WithRequestID(gen),
+		WithHook(func(info MatchInfo) { hooked = append(hooked, info.RequestID) }),
+		WithDeadLetters(ring, DeadLetterOptions{}),
+	)
+	r.Handle("/id", echoID)
+	runRouterTests(t, r, "generated IDs", "/id", []test{
+		{"", "a"},
+		{"", "b"},
+	})
+	if res := r.MatchResult(context.Background(), routing.Request{Path: "/id"}); res.ID != "c" || res.Output != "c" {
+		t.Errorf("expected the ID from the generator to be returned, got: %+v", res)
+	}
+	ctx := ContextWithRequestID(context.Background(), "supplied")
+	if res, _ := r.MatchContext(ctx, routing.Request{Path: "/id"}); res != "supplied" {
+		t.Errorf("expected supplied ID, got: %s", res)
+	}
+	r.Match(routing.Request{Path: "/nope"})
+
+	expected := []string{"a", "b", "c", "supplied", "d"}
+	if strings.Join(hooked, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected hooks to see IDs: %v, got: %v", expected, hooked)
+	}
+	if letters := ring.Letters(); len(letters) != 1 || letters[0].RequestID != "d" {
+		t.Errorf("expected dead letter with the generated ID, got: %+v", letters)
+	}
+}
+
+func TestMatchResult(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&b, nil))
+	var hooked []string
+	r := New(
+		WithMiddleware(Logging(logger, LogOptions{})),
+		WithHook(func(info MatchInfo) { hooked = append(hooked, info.RequestID) }),
+	)
+	r.Handle("/id", echoID)
+
+	res := r.MatchResult(context.Background(), routing.Request{Path: "/id"})
+	if res.Err != nil || res.ID == "" || res.Output != res.ID {
+		t.Errorf("expected handler to see the returned ID, got: %+v", res)
+	}
+	other := r.MatchResult(context.Background(), routing.Request{Path: "/id"})
+	if other.ID == res.ID {
+		t.Errorf("expected unique IDs, got %s twice", res.ID)
+	}
+	ctx := ContextWithRequestID(context.Background(), "supplied")
+	if res := r.MatchResult(ctx, routing.Request{Path: "/nope"}); res.ID != "supplied" || res.Err == nil {
+		t.Errorf("expected supplied ID and an error, got: %+v", res)
+	}
+
+	expected := []string{res.ID, other.ID, "supplied"}
+	for i, rec := range decodeLog(t, &b) {
+		if rec["request_id"] != expected[i] {
+			t.Errorf("log record %d, expected request ID: %s, got: %v", i, expected[i], rec["request_id"])
+		}
+	}
+	for i, id := range hooked {
+		if id != expected[i] {
+			t.Errorf("hook %d, expected request ID: %s, got: %s", i, expected[i], id)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Attempt describes a finished attempt of the Retry middleware.
+type Attempt struct {
+	// Number of the attempt, starting with 1
+	Number int
+	Err    error
+	// Delay before the next attempt, zero if there is none
+	Delay time.Duration
+}
+
+// RetryOptions configures the Retry middleware.
+type RetryOptions struct {
+	// Attempts is the maximum number of attempts, including the first one. At least 1.
+	Attempts int
+	// Backoff returns the delay after the given failed attempt, no delay if not set
+	Backoff func(attempt int) time.Duration
+	// Retryable tells whether an error is worth another attempt. By default all errors are,
+	// except for cancellation of the request context.
+	Retryable func(error) bool
+	// OnAttempt, if set, is called after every attempt, successful or not
+	OnAttempt func(ctx context.Context, a Attempt)
+	// Sleep waits for d or until ctx is done, returning ctx.Err() in the latter case.
+	// It is only meant to be replaced in tests.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Exponential is a backoff policy where the delay doubles with every attempt.
+type Exponential struct {
+	// Base is the delay after the first attempt
+	Base time.Duration
+	// Max caps the delay, no cap if zero
+	Max time.Duration
+	// Jitter is the fraction of the delay that is randomized, from 0 to 1. With jitter j
+	// the delay is picked uniformly from [d*(1-j), d].
+	Jitter float64
+	// Rand returns a number in [0, 1), rand.Float64 by default
+	Rand func() float64
+}
+
+// Backoff returns the delay after the given attempt, use it as RetryOptions.Backoff.
+func (e Exponential) Backoff(attempt int) time.Duration {
+	d := e.Base
+	// without a cap the delay stops growing before it overflows
+	for i := 1; i < attempt && (e.Max == 0 || d < e.Max) && d <= math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if e.Max > 0 && d > e.Max {
+		d = e.Max
+	}
+	if e.Jitter > 0 {
+		random := e.Rand
+		if random == nil {
+			random = rand.Float64
+		}
+		d -= time.Duration(float64(d) * e.Jitter * random())
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type attemptKey struct{}
+
+// AttemptFrom returns the number of the current attempt of the Retry middleware,
+// zero if the request is not retried.
+func AttemptFrom(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Retry returns a middleware that calls the rest of the chain again when it fails with
+// a retryable error, up to the given number of attempts. Middlewares after Retry run
+// on every attempt and can find out which one it is with AttemptFrom.
+// The error of the last attempt is returned, or the context error if the request
+// context is done while waiting for the next attempt.
+func Retry(opts RetryOptions) Middleware {
+	attempts := max(opts.Attempts, 1)
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = func(err error) bool { return !isContextError(err) }
+	}
+	wait := opts.Sleep
+	if wait == nil {
+		wait = sleep
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			for n := 1; ; n++ {
+				out, err := next(context.WithValue(ctx, attemptKey{}, n), in)
+				a := Attempt{Number: n, Err: err}
+				last := err == nil || n == attempts || !retryable(err) || ctx.Err() != nil
+				if !last && opts.Backoff != nil {
+					a.Delay = opts.Backoff(n)
+				}
+				if opts.OnAttempt != nil {
+					opts.OnAttempt(ctx, a)
+				}
+				if last {
+					return out, err
+				}
+				if err := wait(ctx, a.Delay); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+var errTemporary = errors.New("temporary")
+var errPermanent = errors.New("permanent")
+
+// failing returns a handler that fails with the given errors in turn, and then succeeds
+func failing(errs ...error) Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		if len(errs) == 0 {
+			return in, nil
+		}
+		err := errs[0]
+		errs = errs[1:]
+		return "", err
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		err      error
+		attempts int
+	}{
+		{"success", nil, nil, 1},
+		{"retried", []error{errTemporary, errTemporary}, nil, 3},
+		{"out of attempts", []error{errTemporary, errTemporary, errTemporary, errTemporary}, errTemporary, 3},
+		{"not retryable", []error{errTemporary, errPermanent}, errPermanent, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts []Attempt
+			var slept []time.Duration
+			r := New()
+			r.Handle("/r", failing(test.errs...))
+			r.Use("/r", Retry(RetryOptions{
+				Attempts:  3,
+				Backoff:   Exponential{Base: time.Second}.Backoff,
+				Retryable: func(err error) bool { return err != errPermanent },
+				OnAttempt: func(ctx context.Context, a Attempt) { attempts = append(attempts, a) },
+				Sleep: func(ctx context.Context, d time.Duration) error {
+					slept = append(slept, d)
+					return nil
+				},
+			}))
+			_, err := r.Match(routing.Request{Path: "/r"})
+			if err != test.err {
+				t.Errorf("expected error: %v, got: %v", test.err, err)
+			}
+			if len(attempts) != test.attempts {
+				t.Fatalf("expected %d attempts, got: %+v", test.attempts, attempts)
+			}
+			for i, a := range attempts {
+				if a.Number != i+1 {
+					t.Errorf("expected attempt number %d, got: %d", i+1, a.Number)
+				}
+			}
+			if len(slept) != test.attempts-1 {
+				t.Errorf("expected %d sleeps, got: %v", test.attempts-1, slept)
+			}
+			for i, d := range slept {
+				if expected := time.Second << i; d != expected {
+					t.Errorf("sleep %d, expected: %v, got: %v", i, expected, d)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	defer checkLeaks(t)()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New()
+	r.Handle("/r", func(ctx context.Context, in string) (string, error) {
+		if AttemptFrom(ctx) == 2 {
+			t.Errorf("no attempts expected after cancellation")
+		}
+		cancel()
+		return "", errTemporary
+	})
+	r.Use("/r", Retry(RetryOptions{Attempts: 3, Backoff: Exponential{Base: time.Hour}.Backoff}))
+	if _, err := r.MatchContext(ctx, routing.Request{Path: "/r"}); !errors.Is(err, errTemporary) {
+		t.Errorf("expected the error of the last attempt, got: %v", err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	e := Exponential{Base: 100 * time.Millisecond, Max: time.Second}
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, exp := range expected {
+		if d := e.Backoff(i + 1); d != exp*time.Millisecond {
+			t.Errorf("attempt %d, expected: %v, got: %v", i+1, exp*time.Millisecond, d)
+		}
+	}
+	e.Jitter = 0.5
+	e.Rand = func() float64 { return 0.5 }
+	if d := e.Backoff(2); d != 150*time.Millisecond {
+		t.Errorf("expected jittered delay 150ms, got: %v", d)
+	}
+
+	// without a cap the delay keeps growing until it saturates instead of overflowing
+	uncapped := Exponential{Base: time.Second}
+	prev := uncapped.Backoff(34)
+	for _, attempt := range []int{35, 70, 1000} {
+		d := uncapped.Backoff(attempt)
+		if d < prev {
+			t.Errorf("attempt %d, expected delay of at least %v, got: %v", attempt, prev, d)
+		}
+		prev = d
+	}
+}
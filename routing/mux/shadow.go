@@ -0,0 +1,154 @@
+package mux
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Divergence is a request for which the candidate of a Shadow behaved differently
+// from the primary handler.
+type Divergence struct {
+	Route, Input string
+	// Primary and Candidate are outputs of the handlers
+	Primary, Candidate string
+	// PrimaryErr and CandidateErr are error messages, empty if there was no error
+	PrimaryErr, CandidateErr string
+	Time                     time.Time
+}
+
+// DiffSink receives divergences found by a Shadow. Report is called from background
+// goroutines and may be called concurrently.
+type DiffSink interface {
+	Report(Divergence)
+}
+
+// DiffRecorder is a DiffSink that keeps divergences in memory.
+type DiffRecorder struct {
+	mu    sync.Mutex
+	diffs []Divergence
+}
+
+// Report implements DiffSink.
+func (r *DiffRecorder) Report(d Divergence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diffs = append(r.diffs, d)
+}
+
+// Divergences returns all divergences reported so far.
+func (r *DiffRecorder) Divergences() []Divergence {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Divergence(nil), r.diffs...)
+}
+
+// ShadowOptions configures a Shadow.
+type ShadowOptions struct {
+	// SampleRate is the fraction of requests mirrored to the candidate, from 0 to 1.
+	// Zero means every request is mirrored.
+	SampleRate float64
+	// MaxInFlight limits the number of candidate calls running at once, requests
+	// over the limit are not mirrored. 16 if not set.
+	MaxInFlight int
+	// Timeout bounds every candidate call, 10 seconds if not set
+	Timeout time.Duration
+	// Sink receives divergences
+	Sink DiffSink
+	// Rand returns a number in [0, 1) and is used for sampling, rand.Float64 by default
+	Rand func() float64
+}
+
+// Shadow mirrors live requests to a candidate handler, for example a rewrite of the primary
+// one, and reports every request for which the outputs differ.
+// The candidate runs in the background on a copy of the input: its output is discarded,
+// and it never delays the response or changes the result of the primary handler, even
+// if it panics. Requests are dropped from mirroring when too many candidate calls are
+// already running.
+type Shadow struct {
+	candidate Handler
+	opts      ShadowOptions
+	random    func() float64
+	slots     chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewShadow creates a shadow for the candidate handler.
+func NewShadow(candidate Handler, opts ShadowOptions) *Shadow {
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 16
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	random := opts.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	return &Shadow{
+		candidate: candidate,
+		opts:      opts,
+		random:    random,
+		slots:     make(chan struct{}, opts.MaxInFlight),
+	}
+}
+
+// Wait waits for all candidate calls that are running to finish.
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// Middleware returns a middleware that calls the rest of the chain as the primary handler,
+// and mirrors the request to the candidate with the same input.
+func (s *Shadow) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			out, err := next(ctx, in)
+			if s.opts.SampleRate > 0 && s.random() >= s.opts.SampleRate {
+				return out, err
+			}
+			select {
+			case s.slots <- struct{}{}:
+			default:
+				return out, err
+			}
+			// the candidate must outlive the request, but keep its values
+			cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.opts.Timeout)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() { <-s.slots }()
+				defer cancel()
+				var cout string
+				var cerr error
+				func() {
+					defer recoverTo(&cerr)
+					cout, cerr = s.candidate(cctx, in)
+				}()
+				if cout == out && errorString(cerr) == errorString(err) {
+					return
+				}
+				if s.opts.Sink != nil {
+					s.opts.Sink.Report(Divergence{
+						Route:        RouteFrom(ctx),
+						Input:        in,
+						Primary:      out,
+						Candidate:    cout,
+						PrimaryErr:   errorString(err),
+						CandidateErr: errorString(cerr),
+						Time:         time.Now(),
+					})
+				}
+			}()
+			return out, err
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,86 @@
+package mux
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestShadow(t *testing.T) {
+	defer checkLeaks(t)()
+	sink := &DiffRecorder{}
+	// the candidate reverses only ASCII correctly, like a naive rewrite would
+	candidate := func(ctx context.Context, in string) (string, error) {
+		b := []byte(in)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return string(b), nil
+	}
+	s := NewShadow(candidate, ShadowOptions{Sink: sink})
+	rev, _ := LookupHandler("reverse")
+	r := New()
+	r.RegisterHandler("/rev", rev)
+	r.Use("/rev", s.Middleware())
+	runRouterTests(t, r, "primary result", "/rev", []test{
+		{"abc", "cba"},
+		{"åb", "bå"},
+	})
+	s.Wait()
+
+	diffs := sink.Divergences()
+	if len(diffs) != 1 {
+		t.Fatalf("expected a single divergence, got: %+v", diffs)
+	}
+	if d := diffs[0]; d.Route != "/rev" || d.Input != "åb" || d.Primary != "bå" || d.Candidate == d.Primary {
+		t.Errorf("unexpected divergence: %+v", d)
+	}
+}
+
+func TestShadowIsolation(t *testing.T) {
+	defer checkLeaks(t)()
+	sink := &DiffRecorder{}
+	release := make(chan struct{})
+	// a candidate that is slow, ignores its input and then panics
+	candidate := func(ctx context.Context, in string) (string, error) {
+		<-release
+		panic("candidate is broken")
+	}
+	rolls := []float64{0.1, 0.9, 0.2}
+	s := NewShadow(candidate, ShadowOptions{
+		Sink:        sink,
+		SampleRate:  0.5,
+		MaxInFlight: 1,
+		Rand: func() float64 {
+			v := rolls[0]
+			rolls = rolls[1:]
+			return v
+		},
+	})
+	r := New()
+	r.RegisterHandler("/id", identity)
+	r.Use("/id", s.Middleware())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	for _, data := range []string{"a", "b", "c"} {
+		if res, err := r.MatchContext(ctx, routing.Request{Path: "/id", Data: data}); res != data || err != nil {
+			t.Errorf("expected primary result %s, got: %s, error: %v", data, res, err)
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("primary handler was delayed by the candidate: %v", d)
+	}
+	// cancelling the request does not cancel the candidate
+	cancel()
+	close(release)
+	s.Wait()
+
+	// a was mirrored, b was not sampled, c was dropped because a was still running
+	diffs := sink.Divergences()
+	if len(diffs) != 1 || diffs[0].Input != "a" || diffs[0].CandidateErr != "panic: candidate is broken" {
+		t.Errorf("expected a single divergence for a, got: %+v", diffs)
+	}
+}
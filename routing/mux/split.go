@@ -0,0 +1,125 @@
+package mux
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+)
+
+// Variant is one of the handlers of a Split.
+type Variant struct {
+	Name string
+	// Weight is the share of requests the variant gets relative to others, variants
+	// with zero weight get no requests
+	Weight  int
+	Handler Handler
+}
+
+// SplitOptions configures a Split.
+type SplitOptions struct {
+	// Key, if set, makes the choice deterministic: requests with the same key always go to
+	// the same variant, as long as the variants and their weights stay the same.
+	// Requests are distributed randomly otherwise.
+	Key KeyFunc
+	// Rand is the source of random choices, seed it to get reproducible results in tests.
+	// A randomly seeded source is used if not set.
+	Rand *rand.Rand
+}
+
+// VariantStats are counters of a variant of a Split.
+type VariantStats struct {
+	Requests, Errors uint64
+}
+
+// Split divides traffic of a route between several handlers by their weights, for example
+// to canary a new version of a handler on a small share of requests.
+type Split struct {
+	variants []Variant
+	total    int
+	key      KeyFunc
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats map[string]*VariantStats
+}
+
+// NewSplit creates a split between the given variants.
+func NewSplit(opts SplitOptions, variants ...Variant) *Split {
+	s := &Split{
+		variants: variants,
+		key:      opts.Key,
+		rand:     opts.Rand,
+		stats:    make(map[string]*VariantStats),
+	}
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	for _, v := range variants {
+		s.total += max(v.Weight, 0)
+		s.stats[v.Name] = &VariantStats{}
+	}
+	return s
+}
+
+// pick returns the variant for a request. s.mu must be held.
+func (s *Split) pick(ctx context.Context) Variant {
+	var n int
+	if s.key != nil {
+		req, _ := RequestFrom(ctx)
+		h := fnv.New64a()
+		h.Write([]byte(s.key(ctx, req)))
+		n = int(h.Sum64() % uint64(s.total))
+	} else {
+		n = s.rand.IntN(s.total)
+	}
+	for _, v := range s.variants {
+		if n < max(v.Weight, 0) {
+			return v
+		}
+		n -= max(v.Weight, 0)
+	}
+	panic("unreachable")
+}
+
+type variantKey struct{}
+
+// VariantFrom returns the name of the variant of a Split that handles the request.
+func VariantFrom(ctx context.Context) string {
+	name, _ := ctx.Value(variantKey{}).(string)
+	return name
+}
+
+// Handler returns a handler that passes every request to one of the variants.
+// It fails with ErrNotFound if no variant has a positive weight.
+func (s *Split) Handler() Handler {
+	return func(ctx context.Context, in string) (string, error) {
+		if s.total == 0 {
+			return "", ErrNotFound
+		}
+		s.mu.Lock()
+		v := s.pick(ctx)
+		st := s.stats[v.Name]
+		st.Requests++
+		s.mu.Unlock()
+
+		out, err := v.Handler(context.WithValue(ctx, variantKey{}, v.Name), in)
+		if err != nil {
+			s.mu.Lock()
+			st.Errors++
+			s.mu.Unlock()
+		}
+		return out, err
+	}
+}
+
+// Stats returns counters of every variant by its name.
+func (s *Split) Stats() map[string]VariantStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]VariantStats, len(s.stats))
+	for name, st := range s.stats {
+		stats[name] = *st
+	}
+	return stats
+}
@@ -0,0 +1,72 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestSplit(t *testing.T) {
+	s := NewSplit(SplitOptions{Rand: rand.New(rand.NewPCG(1, 2))},
+		Variant{Name: "stable", Weight: 95, Handler: constant("stable")},
+		Variant{Name: "canary", Weight: 5, Handler: failing(errTemporary, errTemporary)},
+		Variant{Name: "off", Weight: 0, Handler: constant("off")},
+	)
+	r := New()
+	r.Handle("/split", s.Handler())
+	const n = 10000
+	for range n {
+		r.Match(routing.Request{Path: "/split"})
+	}
+	stats := s.Stats()
+	if stats["stable"].Requests+stats["canary"].Requests != n || stats["off"].Requests != 0 {
+		t.Fatalf("expected all requests to go to weighted variants, got: %+v", stats)
+	}
+	if c := stats["canary"].Requests; c < n*3/100 || c > n*7/100 {
+		t.Errorf("expected about 5%% of requests on canary, got: %d of %d", c, n)
+	}
+	if e := stats["canary"].Errors; e != 2 {
+		t.Errorf("expected 2 canary errors, got: %d", e)
+	}
+}
+
+func TestSplitByKey(t *testing.T) {
+	seen := make(map[string]string)
+	record := func(ctx context.Context, in string) (string, error) {
+		return VariantFrom(ctx), nil
+	}
+	s := NewSplit(SplitOptions{Key: ByKey(clientID)},
+		Variant{Name: "a", Weight: 1, Handler: record},
+		Variant{Name: "b", Weight: 1, Handler: record},
+	)
+	r := New()
+	r.Handle("/split", s.Handler())
+	for round := range 3 {
+		for _, client := range strings.Split("abcdefghijklmnop", "") {
+			res, err := r.Match(routing.Request{Path: "/split", Data: client + ":x"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if round > 0 && seen[client] != res {
+				t.Errorf("client %s, expected variant: %s, got: %s", client, seen[client], res)
+			}
+			seen[client] = res
+		}
+	}
+	stats := s.Stats()
+	if stats["a"].Requests == 0 || stats["b"].Requests == 0 {
+		t.Errorf("expected clients to be spread over both variants, got: %+v", stats)
+	}
+}
+
+func TestSplitNoVariants(t *testing.T) {
+	r := New()
+	r.Handle("/split", NewSplit(SplitOptions{}).Handler())
+	if _, err := r.Match(routing.Request{Path: "/split"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+}
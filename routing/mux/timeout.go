@@ -0,0 +1,65 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned when a handler does not finish in time.
+var ErrTimeout = errors.New("handler timed out")
+
+type result struct {
+	out string
+	err error
+}
+
+// Timeout returns a middleware that bounds the run time of the rest of the chain by d.
+// When time is up, the context passed down the chain is cancelled and ErrTimeout is
+// returned right away. Handlers that ignore the context keep running in the background
+// until they finish, and their result is discarded. Panics in the chain are returned as
+// PanicError, since they happen on a different goroutine and cannot be propagated.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, in string) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			// buffered, so that the handler can finish after nobody waits for it
+			done := make(chan result, 1)
+			go func() {
+				var res result
+				defer func() { done <- res }()
+				defer recoverTo(&res.err)
+				res.out, res.err = next(ctx, in)
+			}()
+			select {
+			case res := <-done:
+				return res.out, res.err
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return "", fmt.Errorf("%w after %v", ErrTimeout, d)
+				}
+				return "", ctx.Err()
+			}
+		}
+	}
+}
+
+// WithTimeout sets the default timeout for every route of the router, see Timeout.
+// Routes can override it with Router.SetTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Router) {
+		r.timeout = d
+	}
+}
+
+// SetTimeout sets the timeout for the given path, overriding the router default.
+// Zero restores the default, a negative duration disables the timeout for the path.
+func (r *Router) SetTimeout(path string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt := r.route(path)
+	rt.timeout = d
+	r.compile(rt)
+}
@@ -0,0 +1,112 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+// checkLeaks records the number of goroutines and returns a function that fails the test
+// if, after a grace period, there are more goroutines than there were initially.
+// Use it as defer checkLeaks(t)().
+func checkLeaks(t *testing.T) func() {
+	before := runtime.NumGoroutine()
+	return func() {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			n := runtime.NumGoroutine()
+			if n <= before {
+				return
+			}
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				t.Errorf("leaked %d goroutines:\n%s", n-before, buf[:runtime.Stack(buf, true)])
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// blocking is a context-aware handler that waits until it is cancelled
+func blocking(ctx context.Context, in string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestTimeout(t *testing.T) {
+	defer checkLeaks(t)()
+	r := New()
+	r.Handle("/block", blocking)
+	r.Use("/block", Timeout(10*time.Millisecond))
+	r.RegisterHandler("/fast", identity)
+	r.Use("/fast", Timeout(time.Second))
+	r.RegisterHandler("/slow", func(in string) string {
+		// ignores the context, but finishes eventually
+		time.Sleep(50 * time.Millisecond)
+		return in
+	})
+	r.Use("/slow", Timeout(10*time.Millisecond))
+
+	for _, path := range []string{"/block", "/slow"} {
+		if _, err := r.Match(routing.Request{Path: path}); !errors.Is(err, ErrTimeout) {
+			t.Errorf("path: %s, expected timeout, got: %v", path, err)
+		}
+	}
+	runRouterTests(t, r, "fast handler", "/fast", []test{
+		{"a", "a"},
+	})
+}
+
+func TestTimeoutCancelled(t *testing.T) {
+	defer checkLeaks(t)()
+	r := New()
+	r.Handle("/block", blocking)
+	r.Use("/block", Timeout(time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.MatchContext(ctx, routing.Request{Path: "/block"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got: %v", err)
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	r := New()
+	r.RegisterHandler("/stub", notImplemented)
+	r.Use("/stub", Timeout(time.Second))
+	var pe *PanicError
+	if _, err := r.Match(routing.Request{Path: "/stub"}); !errors.As(err, &pe) {
+		t.Errorf("expected PanicError, got: %v", err)
+	}
+}
+
+func TestRouterTimeout(t *testing.T) {
+	defer checkLeaks(t)()
+	r := New(WithTimeout(10 * time.Millisecond))
+	r.Handle("/default", blocking)
+	r.Handle("/override", func(ctx context.Context, in string) (string, error) {
+		select {
+		case <-time.After(30 * time.Millisecond):
+			return in, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	r.SetTimeout("/override", time.Second)
+
+	if _, err := r.Match(routing.Request{Path: "/default"}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected default timeout, got: %v", err)
+	}
+	runRouterTests(t, r, "route timeout overrides default", "/override", []test{
+		{"a", "a"},
+	})
+	r.SetTimeout("/override", 0)
+	if _, err := r.Match(routing.Request{Path: "/override"}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected default timeout to be restored, got: %v", err)
+	}
+}
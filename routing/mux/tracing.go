@@ -0,0 +1,206 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation within a trace. Every matched request produces a root span,
+// with a child span for each middleware layer and one for the handler. Since middlewares
+// call the next layer themselves, spans are nested: a middleware span includes the time
+// of all the layers after it.
+type Span struct {
+	TraceID  string        `json:"trace_id"`
+	SpanID   string        `json:"span_id"`
+	ParentID string        `json:"parent_id,omitempty"`
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Exporter receives finished spans. Export may be called concurrently.
+type Exporter interface {
+	Export(Span)
+}
+
+// Tracer creates spans and passes them to an exporter when they end.
+// Install it on a router with WithTracer.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates a tracer that exports spans to e.
+func NewTracer(e Exporter) *Tracer {
+	return &Tracer{exporter: e}
+}
+
+// WithTracer traces every matched request with t.
+func WithTracer(t *Tracer) Option {
+	return func(r *Router) {
+		r.tracer = t
+	}
+}
+
+type spanKey struct{}
+
+type spanContext struct {
+	traceID, spanID string
+}
+
+// ContextWithTraceID makes the spans of a request, matched with this context, part of an existing trace.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, spanKey{}, spanContext{traceID: traceID})
+}
+
+// TraceIDFrom returns the ID of the trace the request belongs to, or an empty string
+// if the request is not traced.
+func TraceIDFrom(ctx context.Context) string {
+	sc, _ := ctx.Value(spanKey{}).(spanContext)
+	return sc.traceID
+}
+
+func newID(bytes int) string {
+	var b strings.Builder
+	for b.Len() < bytes*2 {
+		fmt.Fprintf(&b, "%016x", rand.Uint64())
+	}
+	return b.String()[:bytes*2]
+}
+
+// start begins a span as a child of the span in ctx, or as a root of a new trace.
+// It returns a context carrying the new span and a function that ends it.
+// A nil tracer does nothing.
+func (t *Tracer) start(ctx context.Context, name string) (context.Context, func(error)) {
+	if t == nil {
+		return ctx, func(error) {}
+	}
+	parent, _ := ctx.Value(spanKey{}).(spanContext)
+	span := Span{
+		TraceID:  parent.traceID,
+		SpanID:   newID(8),
+		ParentID: parent.spanID,
+		Name:     name,
+		Start:    time.Now(),
+	}
+	if span.TraceID == "" {
+		span.TraceID = newID(16)
+	}
+	ctx = context.WithValue(ctx, spanKey{}, spanContext{traceID: span.TraceID, spanID: span.SpanID})
+	return ctx, func(err error) {
+		span.Duration = time.Since(span.Start)
+		if err != nil {
+			span.Error = err.Error()
+		}
+		t.exporter.Export(span)
+	}
+}
+
+// layer wraps h so that every call to it is a span. A nil tracer returns h as is.
+func (t *Tracer) layer(name string, h Handler) Handler {
+	if t == nil {
+		return h
+	}
+	return func(ctx context.Context, in string) (string, error) {
+		ctx, end := t.start(ctx, name)
+		out, err := h(ctx, in)
+		end(err)
+		return out, err
+	}
+}
+
+// funcName returns a short name of a function, like "mux.Logging.func1"
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// Collector is an exporter that keeps spans in memory.
+type Collector struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// Export implements Exporter.
+func (c *Collector) Export(s Span) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans = append(c.spans, s)
+}
+
+// Spans returns all spans collected so far, in the order they ended.
+func (c *Collector) Spans() []Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Span(nil), c.spans...)
+}
+
+// Trace returns the spans of a single trace, in the order they ended.
+func (c *Collector) Trace(traceID string) []Span {
+	var spans []Span
+	for _, s := range c.Spans() {
+		if s.TraceID == traceID {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// JSONExporter writes every span as a line of JSON.
+type JSONExporter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONExporter creates an exporter writing to w.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w, enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter creates an exporter appending to the named file, creating it if needed.
+// Close the exporter to close the file.
+func NewFileExporter(name string) (*JSONExporter, error) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONExporter(f), nil
+}
+
+// Export implements Exporter. Spans are dropped after the first write error,
+// which is reported by Close.
+func (e *JSONExporter) Export(s Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = e.enc.Encode(s)
+	}
+}
+
+// Close returns the first write error, and closes the underlying writer if it is an io.Closer.
+func (e *JSONExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.err
+	if c, ok := e.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
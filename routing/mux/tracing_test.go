@@ -0,0 +1,104 @@
+package mux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/i-hate-nicknames/golang_diy/routing"
+)
+
+func TestTracing(t *testing.T) {
+	c := &Collector{}
+	bang, _ := LookupMiddleware("bangify")
+	r := New(WithTracer(NewTracer(c)))
+	var handlerTrace string
+	r.Handle("/traced", func(ctx context.Context, in string) (string, error) {
+		handlerTrace = TraceIDFrom(ctx)
+		return in, nil
+	})
+	r.UseMiddleware("/traced", bang)
+	r.Use("/traced", WrapMiddleware(bang))
+
+	res, err := r.Match(routing.Request{Path: "/traced", Data: "a"})
+	if err != nil || res != "a!!" {
+		t.Fatalf("expected: a!!, got: %s, error: %v", res, err)
+	}
+	spans := c.Spans()
+	// spans end from the innermost to the outermost
+	names := []string{"handler", "middleware 1", "middleware 0", "match /traced"}
+	if len(spans) != len(names) {
+		t.Fatalf("expected %d spans, got: %+v", len(names), spans)
+	}
+	for i, name := range names {
+		s := spans[i]
+		if !strings.HasPrefix(s.Name, name) {
+			t.Errorf("span %d, expected name: %s, got: %s", i, name, s.Name)
+		}
+		if s.TraceID != handlerTrace {
+			t.Errorf("span %d, expected trace: %s, got: %s", i, handlerTrace, s.TraceID)
+		}
+		if i+1 < len(spans) && s.ParentID != spans[i+1].SpanID {
+			t.Errorf("span %s, expected parent: %s, got: %s", s.Name, spans[i+1].Name, s.ParentID)
+		}
+	}
+	if root := spans[len(spans)-1]; root.ParentID != "" {
+		t.Errorf("expected root span without parent, got: %s", root.ParentID)
+	}
+}
+
+func TestTracingExistingTrace(t *testing.T) {
+	c := &Collector{}
+	r := New(WithTracer(NewTracer(c)))
+	r.Handle("/fail", func(ctx context.Context, in string) (string, error) {
+		return "", errors.New("boom")
+	})
+	ctx := ContextWithTraceID(context.Background(), "abc")
+	r.MatchContext(ctx, routing.Request{Path: "/fail"})
+	spans := c.Trace("abc")
+	if len(spans) != 2 {
+		t.Fatalf("expected handler and match spans in the given trace, got: %+v", c.Spans())
+	}
+	for _, s := range spans {
+		if s.Error != "boom" {
+			t.Errorf("span %s, expected error, got: %q", s.Name, s.Error)
+		}
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "spans.jsonl")
+	e, err := NewFileExporter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(WithTracer(NewTracer(e)))
+	r.RegisterHandler("/id", identity)
+	r.Match(routing.Request{Path: "/id"})
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var spans []Span
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s Span
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, s)
+	}
+	if len(spans) != 2 || spans[0].TraceID == "" || spans[0].TraceID != spans[1].TraceID {
+		t.Errorf("expected two spans of one trace, got: %+v", spans)
+	}
+}